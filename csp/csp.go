@@ -0,0 +1,261 @@
+// Package csp sets a Content-Security-Policy compatible with htmx, with a
+// nonce generated for each request. The nonce allows the inline scripts and
+// styles of the page, and is passed to htmx through the "htmx-config" meta tag,
+// so that the indicator styles and scripts inserted by htmx are allowed too.
+//   - https://developer.mozilla.org/en-US/docs/Web/HTTP/CSP
+//   - https://htmx.org/docs/#security
+package csp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"html"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// Source expressions with a special meaning.
+const (
+	Self          = "'self'"
+	None          = "'none'"
+	UnsafeInline  = "'unsafe-inline'"
+	UnsafeEval    = "'unsafe-eval'"
+	StrictDynamic = "'strict-dynamic'"
+	ReportSample  = "'report-sample'"
+	Data          = "data:"
+	HTTPS         = "https:"
+
+	// NonceSource is replaced by the nonce of the request when the policy is
+	// sent.
+	NonceSource = "'nonce'"
+)
+
+// Policy is a Content-Security-Policy, made of directives listing the sources
+// allowed for each type of resource. Policies are immutable; methods return a
+// modified copy.
+type Policy struct {
+	directives []directive
+}
+
+type directive struct {
+	name    string
+	sources []string
+}
+
+// DefaultPolicy returns a strict policy compatible with htmx. Resources are
+// only loaded from the origin of the page, and inline scripts and styles need
+// the nonce of the request:
+//
+//	default-src 'self'; script-src 'self' 'nonce-…'; style-src 'self' 'nonce-…';
+//	img-src 'self' data:; object-src 'none'; base-uri 'self';
+//	form-action 'self'; frame-ancestors 'none'
+//
+// Attributes evaluated as scripts, such as "hx-on" and "hx-vals" with the
+// "js:" prefix, are refused; add UnsafeEval to "script-src" to allow them.
+func DefaultPolicy() Policy {
+	return Policy{}.
+		With("default-src", Self).
+		With("script-src", Self, NonceSource).
+		With("style-src", Self, NonceSource).
+		With("img-src", Self, Data).
+		With("object-src", None).
+		With("base-uri", Self).
+		With("form-action", Self).
+		With("frame-ancestors", None)
+}
+
+// With returns a copy of the policy with the sources added to the directive.
+// Adding sources to a directive containing 'none' replaces it. Directives
+// without sources, such as "upgrade-insecure-requests", are added as is.
+func (p Policy) With(name string, sources ...string) Policy {
+	directives := make([]directive, len(p.directives), len(p.directives)+1)
+	copy(directives, p.directives)
+
+	for i, d := range directives {
+		if d.name != name {
+			continue
+		}
+		merged := make([]string, 0, len(d.sources)+len(sources))
+		for _, source := range d.sources {
+			if source != None || len(sources) == 0 {
+				merged = append(merged, source)
+			}
+		}
+		for _, source := range sources {
+			if !contains(merged, source) {
+				merged = append(merged, source)
+			}
+		}
+		directives[i].sources = merged
+		return Policy{directives: directives}
+	}
+
+	return Policy{directives: append(directives, directive{name: name, sources: append([]string(nil), sources...)})}
+}
+
+// Without returns a copy of the policy without the directive.
+func (p Policy) Without(name string) Policy {
+	directives := make([]directive, 0, len(p.directives))
+	for _, d := range p.directives {
+		if d.name != name {
+			directives = append(directives, d)
+		}
+	}
+	return Policy{directives: directives}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the value of the policy header, with the NonceSource
+// replaced by the nonce. If the nonce is empty, the NonceSource is omitted.
+func (p Policy) String(nonce string) string {
+	parts := make([]string, 0, len(p.directives))
+	for _, d := range p.directives {
+		part := d.name
+		for _, source := range d.sources {
+			if source == NonceSource {
+				if nonce == "" {
+					continue
+				}
+				source = "'nonce-" + nonce + "'"
+			}
+			part += " " + source
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Middleware sets the Content-Security-Policy header of every response, with a
+// nonce generated for each request. For example, a policy also allowing the
+// htmx script and Google fonts:
+//
+//	policy := csp.DefaultPolicy().
+//		With("script-src", "https://unpkg.com").
+//		With("style-src", "https://fonts.googleapis.com").
+//		With("font-src", "https://fonts.gstatic.com")
+//
+//	secure := csp.Middleware{Policy: policy, ReportURI: "/csp-report"}
+//	mux.Handle("/csp-report", csp.ReportHandler{})
+//	http.ListenAndServe(":3333", secure.Handler(mux))
+//
+// along with templates parsed with csp.Funcs(), executed with the nonce of
+// the request:
+//
+//	<head>
+//	    {{htmxConfig .CSPNonce}}
+//	    <script {{cspNonce .CSPNonce}}>...</script>
+//	</head>
+type Middleware struct {
+	// The policy of the responses. Defaults to DefaultPolicy().
+	Policy Policy
+	// Sends the policy with the "Content-Security-Policy-Report-Only" header,
+	// so that violations are reported without being blocked. Used to try a
+	// policy out before enforcing it.
+	ReportOnly bool
+	// The url receiving reports of policy violations, typically served by a
+	// ReportHandler. When set, the "report-uri" and "report-to" directives
+	// are added to the policy.
+	ReportURI string
+}
+
+type contextKey struct{}
+
+// Handler wraps the handler with the policy.
+func (m Middleware) Handler(next http.Handler) http.Handler {
+	policy := m.Policy
+	if policy.directives == nil {
+		policy = DefaultPolicy()
+	}
+
+	header := "Content-Security-Policy"
+	if m.ReportOnly {
+		header = "Content-Security-Policy-Report-Only"
+	}
+
+	if m.ReportURI != "" {
+		policy = policy.With("report-uri", m.ReportURI).With("report-to", reportGroup)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce, err := newNonce()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set(header, policy.String(nonce))
+		if m.ReportURI != "" {
+			w.Header().Set("Reporting-Endpoints", reportGroup+`="`+m.ReportURI+`"`)
+		}
+		next.ServeHTTP(w, r.WithContext(ContextWithNonce(r.Context(), nonce)))
+	})
+}
+
+// reportGroup is the name of the reporting endpoint of the "report-to"
+// directive.
+const reportGroup = "csp-endpoint"
+
+func newNonce() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// ContextWithNonce returns a copy of the context holding the nonce.
+func ContextWithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, contextKey{}, nonce)
+}
+
+// NonceFromContext returns the nonce of the context, or an empty string if the
+// context holds no nonce.
+func NonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(contextKey{}).(string)
+	return nonce
+}
+
+// Nonce returns the nonce of the request, which must be served by the
+// middleware. An empty string is returned otherwise.
+func Nonce(r *http.Request) string {
+	return NonceFromContext(r.Context())
+}
+
+// Funcs returns the template functions rendering the nonce of a request:
+//   - cspNonce renders the "nonce" attribute of inline scripts and styles
+//   - htmxConfig renders the "htmx-config" meta tag; see ConfigMeta()
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"cspNonce":   NonceAttr,
+		"htmxConfig": ConfigMeta,
+	}
+}
+
+// NonceAttr returns the "nonce" attribute allowing an inline script or style.
+func NonceAttr(nonce string) template.HTMLAttr {
+	return template.HTMLAttr(`nonce="` + html.EscapeString(nonce) + `"`)
+}
+
+// ConfigMeta returns the "htmx-config" meta tag passing the nonce to htmx
+// through the "inlineScriptNonce" and "inlineStyleNonce" options, so that the
+// scripts and indicator styles inserted by htmx are allowed. The tag must be
+// rendered within the head of the page, before the htmx script.
+//   - https://htmx.org/docs/#config
+func ConfigMeta(nonce string) template.HTML {
+	config, _ := json.Marshal(map[string]string{
+		"inlineScriptNonce": nonce,
+		"inlineStyleNonce":  nonce,
+	})
+	return template.HTML(`<meta name="htmx-config" content="` + html.EscapeString(string(config)) + `">`)
+}
@@ -0,0 +1,152 @@
+package csp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPolicyString(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		nonce  string
+		want   string
+	}{
+		{
+			name:   "default policy",
+			policy: DefaultPolicy(),
+			nonce:  "abc",
+			want: "default-src 'self'; script-src 'self' 'nonce-abc'; style-src 'self' 'nonce-abc'; " +
+				"img-src 'self' data:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+		},
+		{
+			name:   "nonce omitted",
+			policy: Policy{}.With("script-src", Self, NonceSource),
+			want:   "script-src 'self'",
+		},
+		{
+			name:   "sources merged without duplicates",
+			policy: DefaultPolicy().With("script-src", "https://unpkg.com", Self).Without("style-src").Without("img-src"),
+			nonce:  "n",
+			want: "default-src 'self'; script-src 'self' 'nonce-n' https://unpkg.com; " +
+				"object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+		},
+		{
+			name:   "none replaced",
+			policy: Policy{}.With("frame-ancestors", None).With("frame-ancestors", Self),
+			want:   "frame-ancestors 'self'",
+		},
+		{
+			name:   "none kept without sources",
+			policy: Policy{}.With("object-src", None).With("object-src"),
+			want:   "object-src 'none'",
+		},
+		{
+			name:   "directive without sources",
+			policy: Policy{}.With("default-src", Self).With("upgrade-insecure-requests"),
+			want:   "default-src 'self'; upgrade-insecure-requests",
+		},
+		{
+			name:   "unknown directive removed",
+			policy: Policy{}.With("default-src", Self).Without("script-src"),
+			want:   "default-src 'self'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.String(tt.nonce); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyImmutable(t *testing.T) {
+	base := Policy{}.With("script-src", Self)
+	extended := base.With("script-src", UnsafeEval).With("img-src", Data)
+	base.Without("script-src")
+
+	if got := base.String(""); got != "script-src 'self'" {
+		t.Errorf("base policy = %q, modified by its copies", got)
+	}
+	if got := extended.String(""); got != "script-src 'self' 'unsafe-eval'; img-src data:" {
+		t.Errorf("extended policy = %q", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		m         Middleware
+		header    string
+		policy    string
+		endpoints string
+	}{
+		{
+			name:   "default policy",
+			header: "Content-Security-Policy",
+			policy: DefaultPolicy().String("{nonce}"),
+		},
+		{
+			name:   "custom policy",
+			m:      Middleware{Policy: Policy{}.With("script-src", NonceSource)},
+			header: "Content-Security-Policy",
+			policy: "script-src 'nonce-{nonce}'",
+		},
+		{
+			name:      "report only",
+			m:         Middleware{Policy: Policy{}.With("script-src", Self), ReportOnly: true, ReportURI: "/csp-report"},
+			header:    "Content-Security-Policy-Report-Only",
+			policy:    "script-src 'self'; report-uri /csp-report; report-to csp-endpoint",
+			endpoints: `csp-endpoint="/csp-report"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var nonces []string
+			handler := tt.m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nonces = append(nonces, Nonce(r))
+			}))
+
+			var headers []http.Header
+			for i := 0; i < 2; i++ {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+				headers = append(headers, rec.Header())
+			}
+
+			if len(nonces) != 2 || nonces[0] == "" || nonces[0] == nonces[1] {
+				t.Fatalf("nonces = %q, want two distinct nonces", nonces)
+			}
+			for i, header := range headers {
+				want := strings.ReplaceAll(tt.policy, "{nonce}", nonces[i])
+				if got := header.Get(tt.header); got != want {
+					t.Errorf("%s = %q, want %q", tt.header, got, want)
+				}
+				if got := header.Get("Reporting-Endpoints"); got != tt.endpoints {
+					t.Errorf("Reporting-Endpoints = %q, want %q", got, tt.endpoints)
+				}
+			}
+		})
+	}
+}
+
+func TestNonceOutsideMiddleware(t *testing.T) {
+	if got := Nonce(httptest.NewRequest(http.MethodGet, "/", nil)); got != "" {
+		t.Errorf("Nonce() = %q, want empty", got)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	if got, want := NonceAttr(`a"b`), `nonce="a&#34;b"`; string(got) != want {
+		t.Errorf("NonceAttr() = %s, want %s", got, want)
+	}
+	want := `<meta name="htmx-config" content="{&#34;inlineScriptNonce&#34;:&#34;abc&#34;,&#34;inlineStyleNonce&#34;:&#34;abc&#34;}">`
+	if got := ConfigMeta("abc"); string(got) != want {
+		t.Errorf("ConfigMeta() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+package csp
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+)
+
+// maxReportSize bounds the size of the reports accepted by ReportHandler.
+const maxReportSize = 64 << 10
+
+// Report describes a violation of the policy, sent by the browser.
+type Report struct {
+	// The url of the document in which the violation occurred.
+	DocumentURI string
+	// The url of the resource that was blocked, or "inline" and "eval" for
+	// inline scripts and styles, and evaluated code.
+	BlockedURI string
+	// The directive whose enforcement caused the violation.
+	Directive string
+	// Either "enforce", or "report" for report only policies.
+	Disposition string
+	// The location of the violation, if known.
+	SourceFile   string
+	LineNumber   int
+	ColumnNumber int
+	// The start of the blocked inline script or style, if the directive
+	// allows the 'report-sample' source.
+	Sample string
+}
+
+// ReportHandler receives the violation reports sent by browsers to the
+// "report-uri" and "report-to" endpoints of a policy; see Middleware. Both the
+// "application/csp-report" format, and the "application/reports+json" format
+// of the Reporting API, are accepted.
+type ReportHandler struct {
+	// Receives each report. Defaults to printing the report to the standard
+	// logger.
+	Log func(r *http.Request, report Report)
+}
+
+// legacyReport is the body of "application/csp-report" requests.
+type legacyReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		BlockedURI         string `json:"blocked-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		ColumnNumber       int    `json:"column-number"`
+		ScriptSample       string `json:"script-sample"`
+	} `json:"csp-report"`
+}
+
+// reportingAPIReport is an element of "application/reports+json" requests.
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		BlockedURL         string `json:"blockedURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		ColumnNumber       int    `json:"columnNumber"`
+		Sample             string `json:"sample"`
+	} `json:"body"`
+}
+
+// ServeHTTP decodes the reports of the request, replying with "204 No Content".
+func (h ReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReportSize))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	reports, err := decodeReports(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	for _, report := range reports {
+		if h.Log != nil {
+			h.Log(r, report)
+		} else {
+			log.Printf("csp: %s violation of %q by %q in %s", report.Disposition, report.Directive, report.BlockedURI, report.DocumentURI)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeReports(contentType string, body []byte) ([]Report, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/reports+json" {
+		var batch []reportingAPIReport
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, err
+		}
+		reports := make([]Report, 0, len(batch))
+		for _, b := range batch {
+			if b.Type != "csp-violation" {
+				continue
+			}
+			reports = append(reports, Report{
+				DocumentURI:  b.Body.DocumentURL,
+				BlockedURI:   b.Body.BlockedURL,
+				Directive:    b.Body.EffectiveDirective,
+				Disposition:  b.Body.Disposition,
+				SourceFile:   b.Body.SourceFile,
+				LineNumber:   b.Body.LineNumber,
+				ColumnNumber: b.Body.ColumnNumber,
+				Sample:       b.Body.Sample,
+			})
+		}
+		return reports, nil
+	}
+
+	var legacy legacyReport
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return nil, err
+	}
+	directive := legacy.Report.EffectiveDirective
+	if directive == "" {
+		directive = legacy.Report.ViolatedDirective
+	}
+	return []Report{{
+		DocumentURI:  legacy.Report.DocumentURI,
+		BlockedURI:   legacy.Report.BlockedURI,
+		Directive:    directive,
+		Disposition:  legacy.Report.Disposition,
+		SourceFile:   legacy.Report.SourceFile,
+		LineNumber:   legacy.Report.LineNumber,
+		ColumnNumber: legacy.Report.ColumnNumber,
+		Sample:       legacy.Report.ScriptSample,
+	}}, nil
+}
@@ -0,0 +1,114 @@
+package csp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReportHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		want        []Report
+	}{
+		{
+			name:        "legacy report",
+			contentType: "application/csp-report",
+			body: `{"csp-report": {"document-uri": "https://example.com/snippets", "blocked-uri": "inline",
+				"violated-directive": "script-src-elem", "effective-directive": "script-src",
+				"disposition": "enforce", "source-file": "https://example.com/snippets",
+				"line-number": 12, "column-number": 4, "script-sample": "alert(1)"}}`,
+			status: http.StatusNoContent,
+			want: []Report{{
+				DocumentURI: "https://example.com/snippets", BlockedURI: "inline", Directive: "script-src",
+				Disposition: "enforce", SourceFile: "https://example.com/snippets", LineNumber: 12, ColumnNumber: 4,
+				Sample: "alert(1)",
+			}},
+		},
+		{
+			name:        "legacy report without effective directive",
+			contentType: "application/csp-report",
+			body:        `{"csp-report": {"blocked-uri": "eval", "violated-directive": "script-src", "disposition": "report"}}`,
+			status:      http.StatusNoContent,
+			want:        []Report{{BlockedURI: "eval", Directive: "script-src", Disposition: "report"}},
+		},
+		{
+			name:        "reporting api batch",
+			contentType: "application/reports+json; charset=utf-8",
+			body: `[
+				{"type": "csp-violation", "body": {"documentURL": "https://example.com/", "blockedURL": "https://evil.com/x.js",
+					"effectiveDirective": "script-src-elem", "disposition": "enforce", "lineNumber": 3, "sample": ""}},
+				{"type": "deprecation", "body": {}},
+				{"type": "csp-violation", "body": {"blockedURL": "inline", "effectiveDirective": "style-src", "disposition": "report"}}
+			]`,
+			status: http.StatusNoContent,
+			want: []Report{
+				{DocumentURI: "https://example.com/", BlockedURI: "https://evil.com/x.js", Directive: "script-src-elem", Disposition: "enforce", LineNumber: 3},
+				{BlockedURI: "inline", Directive: "style-src", Disposition: "report"},
+			},
+		},
+		{
+			name:        "malformed legacy report",
+			contentType: "application/csp-report",
+			body:        `{"csp-report": `,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "malformed reporting api batch",
+			contentType: "application/reports+json",
+			body:        `{"type": "csp-violation"}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "empty body",
+			contentType: "application/csp-report",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "report too large",
+			contentType: "application/csp-report",
+			body:        `{"csp-report": {"script-sample": "` + strings.Repeat("x", maxReportSize) + `"}}`,
+			status:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "wrong method",
+			method: http.MethodGet,
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Report
+			handler := ReportHandler{Log: func(r *http.Request, report Report) {
+				got = append(got, report)
+			}}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			r := httptest.NewRequest(method, "/csp-report", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("reports = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("report %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Package csrf protects htmx handlers against cross site request forgery, with
+// tokens signed by a server key and bound to a cookie. Pages send the token
+// with every htmx request through the "hx-headers" attribute, and with plain
+// form submissions through a hidden field.
+//   - https://htmx.org/docs/#csrf-prevention
+package csrf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	"html/template"
+	"io"
+	"net/http"
+
+	"github.com/nisimpson/htmx"
+)
+
+const (
+	// DefaultCookieName is the name of the cookie holding the secret tokens
+	// are bound to.
+	DefaultCookieName = "_csrf"
+
+	// DefaultHeader is the request header carrying the token.
+	DefaultHeader = "X-CSRF-Token"
+
+	// DefaultField is the form field carrying the token.
+	DefaultField = "csrf_token"
+)
+
+var (
+	// ErrMissingToken is passed to the failure handler when an unsafe request
+	// carries no token.
+	ErrMissingToken = errors.New("csrf: missing token")
+
+	// ErrInvalidToken is passed to the failure handler when the token of an
+	// unsafe request was not issued for the cookie of the request.
+	ErrInvalidToken = errors.New("csrf: invalid token")
+)
+
+const (
+	secretSize = 32
+	nonceSize  = 16
+)
+
+// Middleware verifies the token of every unsafe request, that is any request
+// not using the GET, HEAD, OPTIONS or TRACE methods. Tokens are read from the
+// request header, falling back to the form field, and must have been issued
+// with Token() for the secret of the request cookie. The cookie is set on the
+// first request of each client. For example:
+//
+//	protect := csrf.Middleware{Key: key}
+//	http.ListenAndServe(":3333", protect.Handler(mux))
+//
+// along with templates parsed with protect.Funcs(), executed with the token
+// of the request:
+//
+//	<body {{csrfHeaders .CSRFToken}}>
+//	    <form method="post" action="/snippets">{{csrfField .CSRFToken}}...</form>
+//	</body>
+type Middleware struct {
+	// The key signing the tokens, which must be kept secret. It must be at
+	// least 32 bytes long, typically generated with crypto/rand.
+	Key []byte
+	// The name of the cookie. Defaults to DefaultCookieName.
+	CookieName string
+	// The request header carrying the token. Defaults to DefaultHeader.
+	Header string
+	// The form field carrying the token. Defaults to DefaultField.
+	Field string
+	// Omits the "Secure" attribute of the cookie, for servers not using
+	// https. Browsers accept secure cookies from http://localhost.
+	Insecure bool
+	// Responds to failed checks. Defaults to a "403 Forbidden" error fragment
+	// for htmx requests, and a "403 Forbidden" error page otherwise.
+	Failure htmx.ErrorHandler
+}
+
+type contextKey struct{}
+
+// requestState is the state of the middleware attached to the request context.
+type requestState struct {
+	m      *Middleware
+	secret []byte
+}
+
+// Handler wraps the handler with the verification of tokens. Handler panics
+// if the key is shorter than 32 bytes.
+func (m Middleware) Handler(next http.Handler) http.Handler {
+	if len(m.Key) < 32 {
+		panic("csrf: the key must be at least 32 bytes long")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := m.secret(r)
+		if !ok {
+			secret = make([]byte, secretSize)
+			if _, err := rand.Read(secret); err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			http.SetCookie(w, m.cookie(secret))
+		}
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, &requestState{m: &m, secret: secret}))
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get(stringOrDefault(m.Header, DefaultHeader))
+		if token == "" {
+			token = r.PostFormValue(stringOrDefault(m.Field, DefaultField))
+		}
+
+		switch {
+		case token == "":
+			m.fail(w, r, ErrMissingToken)
+		case !ok || !m.verify(secret, token):
+			m.fail(w, r, ErrInvalidToken)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// secret returns the secret held by the request cookie, if valid.
+func (m *Middleware) secret(r *http.Request) ([]byte, bool) {
+	cookie, err := r.Cookie(stringOrDefault(m.CookieName, DefaultCookieName))
+	if err != nil {
+		return nil, false
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || len(secret) != secretSize {
+		return nil, false
+	}
+	return secret, true
+}
+
+func (m *Middleware) cookie(secret []byte) *http.Cookie {
+	return &http.Cookie{
+		Name:     stringOrDefault(m.CookieName, DefaultCookieName),
+		Value:    base64.RawURLEncoding.EncodeToString(secret),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   !m.Insecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// sign returns the signature binding the nonce to the secret.
+func (m *Middleware) sign(secret, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, m.Key)
+	mac.Write(nonce)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+// issue returns a new token for the secret. Each token has a random nonce, so
+// that tokens embedded into responses differ across requests.
+func (m *Middleware) issue(secret []byte) string {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(append(nonce, m.sign(secret, nonce)...))
+}
+
+func (m *Middleware) verify(secret []byte, token string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) != nonceSize+sha256.Size {
+		return false
+	}
+	return hmac.Equal(b[nonceSize:], m.sign(secret, b[:nonceSize]))
+}
+
+func (m *Middleware) fail(w http.ResponseWriter, r *http.Request, err error) {
+	handler := m.Failure
+	if handler == nil {
+		handler = defaultFailure
+	}
+	handler.ServeHTMXError(htmx.NewResponseWriter(w), htmx.NewRequest(r), err)
+}
+
+var defaultFailure = htmx.ErrorRenderer{
+	Fragment: func(r *htmx.Request, err error) htmx.Component {
+		return htmx.ComponentFunc(func(w io.Writer) error {
+			_, err := io.WriteString(w, `<div role="alert">Forbidden: the page has expired, reload it and try again.</div>`)
+			return err
+		})
+	},
+	Page: func(r *htmx.Request, err error) htmx.Component {
+		return htmx.ComponentFunc(func(w io.Writer) error {
+			_, err := io.WriteString(w, "<!doctype html><title>Forbidden</title><p>The page has expired, go back, reload it and try again.</p>")
+			return err
+		})
+	},
+	FragmentStatus: http.StatusForbidden,
+	PageStatus:     http.StatusForbidden,
+}
+
+// Token returns a new token for the client of the request, which must be
+// served by the middleware. An empty string is returned otherwise.
+func Token(r *http.Request) string {
+	state, ok := r.Context().Value(contextKey{}).(*requestState)
+	if !ok {
+		return ""
+	}
+	return state.m.issue(state.secret)
+}
+
+// Funcs returns the template functions rendering the token of a request:
+//   - csrfHeaders renders the "hx-headers" attribute sending the token with
+//     the htmx requests of the element and its children
+//   - csrfField renders the hidden input sending the token with a form
+func (m Middleware) Funcs() template.FuncMap {
+	return template.FuncMap{
+		"csrfHeaders": m.HeadersAttr,
+		"csrfField":   m.FieldHTML,
+	}
+}
+
+// HeadersAttr returns the "hx-headers" attribute sending the token with the
+// htmx requests of the element and its children.
+func (m Middleware) HeadersAttr(token string) template.HTMLAttr {
+	headers, _ := json.Marshal(map[string]string{stringOrDefault(m.Header, DefaultHeader): token})
+	return template.HTMLAttr(`hx-headers="` + html.EscapeString(string(headers)) + `"`)
+}
+
+// FieldHTML returns the hidden input sending the token with a form.
+func (m Middleware) FieldHTML(token string) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + html.EscapeString(stringOrDefault(m.Field, DefaultField)) +
+		`" value="` + html.EscapeString(token) + `">`)
+}
+
+func stringOrDefault(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
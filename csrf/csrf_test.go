@@ -0,0 +1,286 @@
+package csrf
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/nisimpson/htmx"
+)
+
+var testKey = bytes.Repeat([]byte("k"), 32)
+
+// session returns the cookie set by the middleware on a first safe request,
+// along with a token issued for it.
+func session(t *testing.T, m Middleware) (*http.Cookie, string) {
+	t.Helper()
+	var token string
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = Token(r)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || token == "" {
+		t.Fatalf("first request set cookies %v and token %q, want a cookie and a token", cookies, token)
+	}
+	return cookies[0], token
+}
+
+// tamper changes the first byte encoded by the base64 value.
+func tamper(value string) string {
+	if value[0] == 'A' {
+		return "B" + value[1:]
+	}
+	return "A" + value[1:]
+}
+
+func TestMiddlewareVerify(t *testing.T) {
+	m := Middleware{Key: testKey}
+	cookie, token := session(t, m)
+	_, otherToken := session(t, m)
+	foreignCookie, _ := session(t, m)
+	_, foreignKeyToken := session(t, Middleware{Key: bytes.Repeat([]byte("x"), 32)})
+
+	tests := []struct {
+		name    string
+		m       Middleware
+		method  string
+		cookie  *http.Cookie
+		header  string
+		form    url.Values
+		wantErr error
+	}{
+		{
+			name:   "token in header",
+			method: http.MethodPost,
+			cookie: cookie,
+			header: token,
+		},
+		{
+			name:   "token in form field",
+			method: http.MethodPost,
+			cookie: cookie,
+			form:   url.Values{DefaultField: {token}},
+		},
+		{
+			name:   "header preferred to form field",
+			method: http.MethodDelete,
+			cookie: cookie,
+			header: token,
+			form:   url.Values{DefaultField: {"ignored"}},
+		},
+		{
+			name:   "custom header and field",
+			m:      Middleware{Header: "X-Token", Field: "token"},
+			method: http.MethodPut,
+			cookie: cookie,
+			form:   url.Values{"token": {token}},
+		},
+		{
+			name:    "missing token",
+			method:  http.MethodPost,
+			cookie:  cookie,
+			wantErr: ErrMissingToken,
+		},
+		{
+			name:    "token in the default field of a custom field",
+			m:       Middleware{Field: "token"},
+			method:  http.MethodPost,
+			cookie:  cookie,
+			form:    url.Values{DefaultField: {token}},
+			wantErr: ErrMissingToken,
+		},
+		{
+			name:    "missing cookie",
+			method:  http.MethodPost,
+			header:  token,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "tampered cookie",
+			method:  http.MethodPost,
+			cookie:  &http.Cookie{Name: DefaultCookieName, Value: tamper(cookie.Value)},
+			header:  token,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "malformed cookie",
+			method:  http.MethodPost,
+			cookie:  &http.Cookie{Name: DefaultCookieName, Value: "not base64!"},
+			header:  token,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "cookie of another client",
+			method:  http.MethodPost,
+			cookie:  foreignCookie,
+			header:  token,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "tampered token",
+			method:  http.MethodPost,
+			cookie:  cookie,
+			header:  tamper(token),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "token of another client",
+			method:  http.MethodPost,
+			cookie:  cookie,
+			header:  otherToken,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "token signed by another key",
+			method:  http.MethodPost,
+			cookie:  cookie,
+			header:  foreignKeyToken,
+			wantErr: ErrInvalidToken,
+		},
+		{name: "get", method: http.MethodGet},
+		{name: "head", method: http.MethodHead},
+		{name: "options", method: http.MethodOptions},
+		{name: "trace", method: http.MethodTrace},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotErr error
+			served := false
+			m := tt.m
+			m.Key = testKey
+			m.Failure = htmx.ErrorHandlerFunc(func(w *htmx.ResponseWriter, r *htmx.Request, err error) {
+				gotErr = err
+			})
+			handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served = true
+			}))
+
+			var r *http.Request
+			if tt.form != nil {
+				r = httptest.NewRequest(tt.method, "/", strings.NewReader(tt.form.Encode()))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				r = httptest.NewRequest(tt.method, "/", nil)
+			}
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			if tt.header != "" {
+				r.Header.Set(stringOrDefault(m.Header, DefaultHeader), tt.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if !errors.Is(gotErr, tt.wantErr) || (gotErr == nil) != (tt.wantErr == nil) {
+				t.Errorf("failure error = %v, want %v", gotErr, tt.wantErr)
+			}
+			if served != (tt.wantErr == nil) {
+				t.Errorf("served = %v, want %v", served, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestMiddlewareCookie(t *testing.T) {
+	cookie, _ := session(t, Middleware{Key: testKey})
+	if cookie.Name != DefaultCookieName || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie = %+v, want an http only, secure, lax %s cookie", cookie, DefaultCookieName)
+	}
+
+	// clients holding a valid cookie keep it.
+	handler := Middleware{Key: testKey}.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("cookies = %v, want none", cookies)
+	}
+
+	insecure, _ := session(t, Middleware{Key: testKey, Insecure: true, CookieName: "token"})
+	if insecure.Name != "token" || insecure.Secure {
+		t.Errorf("cookie = %+v, want an insecure token cookie", insecure)
+	}
+}
+
+func TestMiddlewareDefaultFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "htmx request",
+			headers: map[string]string{htmx.HeaderHXRequest: "true"},
+			want:    `<div role="alert">`,
+		},
+		{
+			name:    "boosted request",
+			headers: map[string]string{htmx.HeaderHXRequest: "true", htmx.HeaderHXBoosted: "true"},
+			want:    "<!doctype html>",
+		},
+		{
+			name: "plain request",
+			want: "<!doctype html>",
+		},
+	}
+
+	handler := Middleware{Key: testKey}.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler served a request without a token")
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/snippets", nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != http.StatusForbidden || !strings.HasPrefix(rec.Body.String(), tt.want) {
+				t.Errorf("response = %d %q, want %d %q...", rec.Code, rec.Body.String(), http.StatusForbidden, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareShortKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Handler() did not panic on a short key")
+		}
+	}()
+	Middleware{Key: []byte("short")}.Handler(http.NotFoundHandler())
+}
+
+func TestToken(t *testing.T) {
+	if got := Token(httptest.NewRequest(http.MethodGet, "/", nil)); got != "" {
+		t.Errorf("Token() outside the middleware = %q, want empty", got)
+	}
+
+	var tokens []string
+	handler := Middleware{Key: testKey}.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, Token(r), Token(r))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(tokens) != 2 || tokens[0] == tokens[1] {
+		t.Errorf("tokens = %q, want two distinct tokens", tokens)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	m := Middleware{Key: testKey, Header: "X-Token", Field: "token"}
+	if got, want := m.HeadersAttr(`a"b`), `hx-headers="{&#34;X-Token&#34;:&#34;a\&#34;b&#34;}"`; string(got) != want {
+		t.Errorf("HeadersAttr() = %s, want %s", got, want)
+	}
+	if got, want := m.FieldHTML(`a"b`), `<input type="hidden" name="token" value="a&#34;b">`; string(got) != want {
+		t.Errorf("FieldHTML() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package htmx
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"log"
+	"net/http"
+)
+
+// ErrorHandler instances respond to errors that occur while rendering a
+// component with WriteComponent().
+type ErrorHandler interface {
+	// ServeHTMXError responds to the error that occurred while serving the
+	// request. No part of the response has been written.
+	ServeHTMXError(*ResponseWriter, *Request, error)
+}
+
+// The ErrorHandlerFunc type is an adapter to allow the use of ordinary functions as
+// error handlers. If f is a function with the appropriate signature,
+// ErrorHandlerFunc(f) is an ErrorHandler that calls f.
+type ErrorHandlerFunc func(*ResponseWriter, *Request, error)
+
+// ServeHTMXError calls f(w, r, err).
+func (f ErrorHandlerFunc) ServeHTMXError(w *ResponseWriter, r *Request, err error) {
+	f(w, r, err)
+}
+
+// DefaultErrorHandler is used by handlers wrapped without WithErrorHandler().
+// It logs the error, along with the request method and path, to the standard
+// logger, and replies with a plain text "Internal Server Error", keeping the
+// error from the client.
+var DefaultErrorHandler ErrorHandler = ErrorHandlerFunc(func(w *ResponseWriter, r *Request, err error) {
+	logError(r, err)
+	internalServerError(w)
+})
+
+func logError(r *Request, err error) {
+	log.Printf("htmx: %s %s: %v", r.Method, r.URL.Path, err)
+}
+
+func internalServerError(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// handleRenderError passes the error to the error handler of the response
+// writer. Writers not created by HTMX() have no request to pass along, and
+// reply with a plain text error instead.
+func handleRenderError(w http.ResponseWriter, err error) {
+	writer, ok := w.(*ResponseWriter)
+	if !ok || writer.state == nil || writer.state.request == nil {
+		internalServerError(w)
+		return
+	}
+
+	handler := writer.state.errorHandler
+	if handler == nil {
+		handler = DefaultErrorHandler
+	}
+	handler.ServeHTMXError(writer, writer.state.request, err)
+}
+
+// ErrorRenderer is an ErrorHandler that logs the error and renders it in a
+// form suited to the request. Requests swapping a fragment receive an error
+// fragment, which may be redirected to a global error region of the page with
+// the Target and Swap fields; all other requests, including boosted and
+// history restore requests, receive a full error page. For example:
+//
+//	handler := htmx.HTMX(h, htmx.WithErrorHandler(htmx.ErrorRenderer{
+//		Fragment: func(r *htmx.Request, err error) htmx.Component {
+//			return alert{Message: "Something went wrong"}
+//		},
+//		Page: func(r *htmx.Request, err error) htmx.Component {
+//			return errorPage{Message: "Something went wrong"}
+//		},
+//		Target: "#errors",
+//		Swap:   htmx.NewSwap(htmx.SwapInnerHTML),
+//	}))
+type ErrorRenderer struct {
+	// Log receives every error. Defaults to printing the error, along with the
+	// request method and path, to the standard logger.
+	Log func(r *Request, err error)
+	// Fragment renders the error for htmx requests swapping a fragment.
+	// Defaults to a <div role="alert"> element.
+	Fragment func(r *Request, err error) Component
+	// Page renders the error as a full html document for all other requests.
+	// Defaults to a plain text response.
+	Page func(r *Request, err error) Component
+	// CSS selector of the element receiving the error fragment, set via the
+	// "HX-Retarget" header. When empty, the target of the request is used.
+	Target string
+	// How the error fragment is swapped, set via the "HX-Reswap" header. When
+	// unset, the swap of the request is used.
+	Swap Swap
+	// Status code of the error fragment response. Defaults to 200 OK, since
+	// htmx does not swap the content of error responses unless configured to.
+	FragmentStatus int
+	// Status code of the error page response. Defaults to 500 Internal
+	// Server Error.
+	PageStatus int
+}
+
+// ServeHTMXError logs the error and renders the error fragment or page.
+func (e ErrorRenderer) ServeHTMXError(w *ResponseWriter, r *Request, err error) {
+	if e.Log != nil {
+		e.Log(r, err)
+	} else {
+		logError(r, err)
+	}
+
+	if r.HTMX().Type == RequestTypePartial {
+		e.writeFragment(w, r, err)
+		return
+	}
+
+	if e.Page == nil {
+		internalServerError(w)
+		return
+	}
+	writeErrorComponent(w, e.Page(r, err), statusOrDefault(e.PageStatus, http.StatusInternalServerError))
+}
+
+func (e ErrorRenderer) writeFragment(w *ResponseWriter, r *Request, err error) {
+	var fragment Component = ComponentFunc(defaultErrorFragment)
+	if e.Fragment != nil {
+		fragment = e.Fragment(r, err)
+	}
+	if e.Target != "" {
+		if err := w.SetRetargetHeader(e.Target); err != nil {
+			log.Printf("htmx: error renderer: %v", err)
+		}
+	}
+	if e.Swap != (Swap{}) {
+		if err := w.SetReswapHeader(e.Swap); err != nil {
+			log.Printf("htmx: error renderer: %v", err)
+		}
+	}
+	writeErrorComponent(w, fragment, statusOrDefault(e.FragmentStatus, http.StatusOK))
+}
+
+func defaultErrorFragment(w io.Writer) error {
+	_, err := io.WriteString(w, `<div role="alert">`+html.EscapeString(http.StatusText(http.StatusInternalServerError))+`</div>`)
+	return err
+}
+
+// writeErrorComponent renders the error component, replying with a plain text
+// error if the error component itself fails to render.
+func writeErrorComponent(w *ResponseWriter, component Component, status int) {
+	buf := bytes.Buffer{}
+	if err := component.RenderHTMX(&buf); err != nil {
+		log.Printf("htmx: error renderer: %v", err)
+		w.Header().Del(HeaderHXRetarget)
+		w.Header().Del(HeaderHXReswap)
+		internalServerError(w)
+		return
+	}
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+func statusOrDefault(status, fallback int) int {
+	if status == 0 {
+		return fallback
+	}
+	return status
+}
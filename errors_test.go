@@ -0,0 +1,33 @@
+package htmx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDefaultErrorHandler(t *testing.T) {
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+
+	handler := HTMX(HandlerFunc(func(w *ResponseWriter, r *Request) {
+		WriteComponent(w, ComponentFunc(func(w io.Writer) error {
+			return errors.New("template: secret detail")
+		}), http.StatusOK)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/snippets", nil))
+
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("response = %d %q, want a generic %d", rec.Code, rec.Body.String(), http.StatusInternalServerError)
+	}
+	if got := logged.String(); !strings.Contains(got, "GET /snippets: template: secret detail") {
+		t.Errorf("log = %q, want the request and the error", got)
+	}
+}
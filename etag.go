@@ -0,0 +1,114 @@
+package htmx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// Versioned components report a version key that changes whenever their
+// rendered content changes, such as a revision number or last modified time of
+// the underlying data. WriteComponent() derives the "ETag" header from the
+// version, and skips rendering entirely when the client already has the
+// current version.
+type Versioned interface {
+	Component
+	ComponentVersion() string
+}
+
+// WithETag marks the component for conditional responses. WriteComponent()
+// derives the "ETag" header from a hash of the rendered content, and omits the
+// content when the client already has it. Components that can cheaply report a
+// version should implement Versioned instead, avoiding the render.
+func WithETag(component Component) Component {
+	return etagComponent{component}
+}
+
+type etagComponent struct {
+	Component
+}
+
+// writeConditional handles conditional responses for components opting in via
+// Versioned or WithETag(), returning true if the response was written. If the
+// request "If-None-Match" header matches the entity tag, htmx requests receive
+// a 204 No Content response with "HX-Reswap: none" so that no swap occurs,
+// while other requests receive a 304 Not Modified response. The rendered
+// content is returned for etagComponents, to avoid rendering twice.
+func writeConditional(w http.ResponseWriter, component Component, status int) (bool, []byte, error) {
+	var (
+		etag    string
+		content []byte
+	)
+
+	switch c := component.(type) {
+	case Versioned:
+		etag = entityTag([]byte(c.ComponentVersion()))
+	case etagComponent:
+		buf := bytes.Buffer{}
+		if err := c.Component.RenderHTMX(&buf); err != nil {
+			return false, nil, err
+		}
+		content = buf.Bytes()
+		etag = entityTag(content)
+	default:
+		return false, nil, nil
+	}
+
+	if status != http.StatusOK {
+		return false, content, nil
+	}
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	if h.Get("Cache-Control") == "" {
+		// allow the browser to store the response, revalidating it on every request.
+		h.Set("Cache-Control", "no-cache")
+	}
+
+	r := requestOf(w)
+	if r == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) ||
+		!etagMatch(r.Header.Get("If-None-Match"), etag) {
+		return false, content, nil
+	}
+
+	if r.HTMX().Request {
+		h.Set(HeaderHXReswap, string(SwapNone))
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return true, content, nil
+}
+
+// requestOf returns the request being served by the writer, if known.
+func requestOf(w http.ResponseWriter) *Request {
+	if writer, ok := w.(*ResponseWriter); ok && writer.state != nil {
+		return writer.state.request
+	}
+	return nil
+}
+
+// entityTag returns a strong entity tag derived from the data.
+func entityTag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatch performs the weak comparison of the "If-None-Match" header
+// against the entity tag.
+func etagMatch(header, etag string) bool {
+	if header = strings.TrimSpace(header); header == "" {
+		return false
+	} else if header == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -5,4 +5,13 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// htmx does not swap error responses by default; swap the forms re-rendered
+// with validation errors.
+document.body.addEventListener("htmx:beforeSwap", function (evt) {
+	if (evt.detail.xhr.status === 422) {
+		evt.detail.shouldSwap = true;
+		evt.detail.isError = false;
+	}
+});
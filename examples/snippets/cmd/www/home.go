@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/nisimpson/htmx"
+	"github.com/nisimpson/htmx/csrf"
+	"github.com/nisimpson/htmx/examples/snippets/html/components"
 	"github.com/nisimpson/htmx/examples/snippets/html/pages"
 )
 
@@ -21,5 +23,7 @@ func (s *SnippetBox) home(w *htmx.ResponseWriter, r *htmx.Request) {
 
 	page := pages.HomePage{}
 	page.Snippets = snippets
+	page.Layout = s.layout(r)
+	page.Form = components.SnippetForm{Expires: 365, CSRFToken: csrf.Token(r.Request)}
 	s.render(w, r, page)
 }
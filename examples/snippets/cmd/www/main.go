@@ -1,29 +1,65 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/json"
-	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
-	"path/filepath"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/nisimpson/htmx"
+	"github.com/nisimpson/htmx/csp"
+	"github.com/nisimpson/htmx/csrf"
 	"github.com/nisimpson/htmx/examples/snippets"
+	"github.com/nisimpson/htmx/examples/snippets/html/pages"
 	"github.com/nisimpson/htmx/examples/snippets/pkg/models"
 	"github.com/nisimpson/htmx/examples/snippets/pkg/storage"
+	"github.com/nisimpson/htmx/templates"
 )
 
 func main() {
+	reload := templates.NewLiveReload("/_reload")
+	reload.Extension = "https://unpkg.com/htmx.org@1.9.5/dist/ext/sse.js"
+
+	// tokens are signed with a key generated on startup, so pages served
+	// before a restart must be reloaded before submitting forms.
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalln(err)
+	}
+	protect := csrf.Middleware{Key: key}
+
+	// allow the htmx scripts and the fonts hosted by Google, on top of the
+	// policy required by htmx.
+	policy := csp.DefaultPolicy().
+		With("script-src", "https://unpkg.com").
+		With("style-src", "https://fonts.googleapis.com").
+		With("font-src", "https://fonts.gstatic.com")
+
 	app := SnippetBox{
 		SnippetModel: models.SnippetModel{
 			Store: storage.NewMemoryStorage(),
 		},
-		Templates: initTemplateCache(),
+		Templates:  newTemplateSet(reload, protect),
+		LiveReload: reload,
+		CSRF:       protect,
+		CSP:        csp.Middleware{Policy: policy, ReportURI: "/csp-report"},
+	}
+	if err := app.Templates.ParseAll(); err != nil {
+		log.Fatalln(err)
+	}
+
+	// when built with the htmxdev tag, reload open pages whenever a template
+	// file changes.
+	if templates.DevMode {
+		go app.Templates.Watch(context.Background(), 0, reload.Reload)
 	}
 	err := http.ListenAndServe(":3333", &app)
 	log.Fatalln(err)
@@ -31,7 +67,10 @@ func main() {
 
 type SnippetBox struct {
 	models.SnippetModel
-	Templates map[string]*template.Template
+	Templates  *templates.Set
+	LiveReload *templates.LiveReload
+	CSRF       csrf.Middleware
+	CSP        csp.Middleware
 }
 
 func (s *SnippetBox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -54,19 +93,21 @@ func (s *SnippetBox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	mux.HandleFunc("/snippet", htmx.HTMXFunc(s.viewSnippet))
 	mux.HandleFunc("/snippets/create", htmx.HTMXFunc(s.createSnippet))
 	mux.HandleFunc("/snippets", htmx.HTMXFunc(s.snippets))
-
-	// protect from cross site scripting and serve.
-	s.secureHeaders(mux).ServeHTTP(w, r)
+	mux.HandleFunc("/snippets/validate", htmx.HTMX(s.validateSnippet()))
+	mux.Handle("/_reload", s.LiveReload)
+
+	// protect from cross site scripting and request forgery, and serve. The
+	// policy violation reports posted by browsers carry no csrf token.
+	root := http.NewServeMux()
+	root.Handle("/csp-report", csp.ReportHandler{})
+	root.Handle("/", s.CSRF.Handler(mux))
+	s.secureHeaders(root).ServeHTTP(w, r)
 }
 
 type SnippetView interface {
 	TemplateName() string
 }
 
-type TemplateFilesProvider interface {
-	TemplateFiles() []string
-}
-
 type TemplateDataProvider interface {
 	TemplateData() any
 }
@@ -77,41 +118,20 @@ var functions = template.FuncMap{
 }
 
 func (s SnippetBox) render(w *htmx.ResponseWriter, r *htmx.Request, view SnippetView) {
-	var fn htmx.ComponentFunc = func(w io.Writer) error {
-		// retrieve the approperiate template set from the cache based on the template name
-		name := view.TemplateName()
-		ts, ok := s.Templates[name]
-
-		// if no entry exists in the cache; query the view for the relevant template
-		// files to be parsed.
-		if !ok {
-			provider, ok := view.(TemplateFilesProvider)
-			if !ok {
-				err := fmt.Errorf("the template %s does not exist", name)
-				log.Println(err)
-				return err
-			}
-
-			parsed, err := template.New(name).
-				Funcs(functions).
-				ParseFiles(provider.TemplateFiles()...)
-
-			if err != nil {
-				log.Print(err.Error())
-				return err
-			}
-			ts = parsed
-		}
+	htmx.WriteComponent(w, s.component(view), http.StatusOK)
+}
 
+func (s SnippetBox) component(view SnippetView) htmx.Component {
+	var fn htmx.ComponentFunc = func(w io.Writer) error {
 		var data any = view
 		if observer, ok := view.(TemplateDataProvider); ok {
 			// allow view to make any changes before template is executed
 			data = observer.TemplateData()
 		}
 
-		// Use the ExecuteTemplate() method to write the content of the
-		// template as the response body.
-		err := ts.ExecuteTemplate(w, view.TemplateName(), data)
+		// render the page or component template from the template set; the
+		// set is parsed the first time each template is rendered.
+		err := s.Templates.View(view.TemplateName(), data).RenderHTMX(w)
 		if err != nil {
 			log.Print(err.Error())
 			return err
@@ -120,7 +140,7 @@ func (s SnippetBox) render(w *htmx.ResponseWriter, r *htmx.Request, view Snippet
 		return nil
 	}
 
-	htmx.WriteComponent(w, fn, http.StatusOK)
+	return fn
 }
 
 func (SnippetBox) serverError(w http.ResponseWriter, err error) {
@@ -138,12 +158,20 @@ func (SnippetBox) noIndex(next http.Handler) http.HandlerFunc {
 	}
 }
 
-func (SnippetBox) secureHeaders(next http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+func (s SnippetBox) secureHeaders(next http.Handler) http.Handler {
+	return s.CSP.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-XSS-Protection", "1;mode=block")
 		w.Header().Set("X-Frame-Options", "deny")
 
 		next.ServeHTTP(w, r)
+	}))
+}
+
+// layout returns the data of the base layout for the request.
+func (SnippetBox) layout(r *htmx.Request) pages.Layout {
+	return pages.Layout{
+		CSRFToken: csrf.Token(r.Request),
+		CSPNonce:  csp.Nonce(r.Request),
 	}
 }
 
@@ -152,36 +180,30 @@ func (SnippetBox) logJSON(data any) {
 	log.Println(string(out))
 }
 
-func initTemplateCache() map[string]*template.Template {
-	cache := make(map[string]*template.Template)
-	rootDir := snippets.RootDir()
-
-	pages, err := filepath.Glob(filepath.Join(rootDir, "html/pages/*.tmpl"))
-	if err != nil {
-		panic(err)
+func newTemplateSet(reload *templates.LiveReload, protect csrf.Middleware) *templates.Set {
+	funcs := protect.Funcs()
+	funcs["liveReload"] = reload.HTML
+	for name, fn := range csp.Funcs() {
+		funcs[name] = fn
+	}
+	for name, fn := range functions {
+		funcs[name] = fn
 	}
 
-	for _, page := range pages {
-		// extract the file name (ex. home.tmpl) from file path
-		name := filepath.Base(page)
-
-		// parse page template file into a template set
-		ts, err := template.New(name).Funcs(functions).ParseFiles(page)
-		if err != nil {
-			panic(err)
-		}
-
-		// add any component templates to the set
-		ts, err = ts.ParseGlob(filepath.Join(rootDir, "html/components/*.tmpl"))
-		if err != nil {
-			panic(err)
-		}
+	// templates are embedded into the binary, unless in dev mode, where they
+	// are read from disk so that changes are picked up without a restart.
+	var files fs.FS = snippets.Files
+	if templates.DevMode {
+		files = os.DirFS(snippets.RootDir())
+	}
 
-		// add template to the cache
-		log.Println("adding template to cache:", name)
-		cache[name] = ts
+	return &templates.Set{
+		FS:       files,
+		Pages:    []string{"html/pages/*.tmpl"},
+		Partials: []string{"html/components/*.tmpl"},
+		Funcs:    funcs,
+		Layout:   "base",
 	}
-	return cache
 }
 
 func currentYear() string {
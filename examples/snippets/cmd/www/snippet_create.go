@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/nisimpson/htmx"
+	"github.com/nisimpson/htmx/csrf"
 	"github.com/nisimpson/htmx/examples/snippets/html/components"
+	"github.com/nisimpson/htmx/examples/snippets/html/pages"
 	"github.com/nisimpson/htmx/examples/snippets/pkg/models"
 )
 
@@ -33,9 +37,23 @@ func (s *SnippetBox) pollSnippets(w *htmx.ResponseWriter, r *htmx.Request) {
 }
 
 func (s *SnippetBox) createSnippet(w *htmx.ResponseWriter, r *htmx.Request) {
+	form := components.SnippetForm{CSRFToken: csrf.Token(r.Request)}
+	err := r.DecodeForm(&form)
+
+	var fieldErrors htmx.FieldErrors
+	if errors.As(err, &fieldErrors) {
+		form.Errors = fieldErrors
+		s.invalidSnippet(w, r, form)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	id, err := s.Store.CreateSnippet(r.Context(), &models.Snippet{
-		Title:   "O snail",
-		Content: "O snail\nClimb Mount Fuji,\nBut slowly, slow-ly!\n\n- Kobayashi Issa",
+		Title:   form.Title,
+		Content: form.Content,
+		Expires: time.Now().AddDate(0, 0, form.Expires),
 	})
 
 	if err != nil {
@@ -44,11 +62,52 @@ func (s *SnippetBox) createSnippet(w *htmx.ResponseWriter, r *htmx.Request) {
 	}
 
 	if r.IsHTMXRequest() {
-		// The snippets polling mechanism will fetch the new snippet, so just return 201
-		w.WriteHeader(http.StatusCreated)
+		// The snippets polling mechanism will fetch the new snippet, so just
+		// reset the form.
+		s.render(w, r, components.SnippetForm{Expires: 365, CSRFToken: csrf.Token(r.Request)})
 		return
 	}
 
 	// redirect to the newly created snippet.
 	http.Redirect(w, r.Request, fmt.Sprintf("/snippet?id=%s", id), http.StatusSeeOther)
 }
+
+// invalidSnippet re-renders the snippet form along with its errors.
+func (s *SnippetBox) invalidSnippet(w *htmx.ResponseWriter, r *htmx.Request, form components.SnippetForm) {
+	if r.IsHTMXRequest() {
+		// replace the form, whichever element submitted it.
+		err := htmx.WriteFormErrors(w, s.component(form), "#snippet-form", htmx.NewSwap(htmx.SwapOuterHTML))
+		if err != nil {
+			s.serverError(w, err)
+		}
+		return
+	}
+
+	snippets, err := s.SnippetModel.FetchAll(r.Context())
+	if err != nil {
+		s.serverError(w, err)
+		return
+	}
+
+	page := pages.HomePage{Layout: s.layout(r), Form: form}
+	page.Snippets = snippets
+	htmx.WriteComponent(w, s.component(page), http.StatusUnprocessableEntity)
+}
+
+// validateSnippet validates the snippet form field that changed, with the
+// rules used when the form is submitted.
+func (s *SnippetBox) validateSnippet() htmx.FieldValidator {
+	return htmx.FieldValidator{
+		Form: func() any { return &components.SnippetForm{} },
+		Render: func(r *htmx.Request, form any, name, problem string) htmx.Component {
+			field := components.SnippetFormField{SnippetForm: *form.(*components.SnippetForm), Name: name}
+			if !field.HasField(name) {
+				return nil
+			}
+			if problem != "" {
+				field.Errors = htmx.FieldErrors{name: problem}
+			}
+			return s.component(field)
+		},
+	}
+}
@@ -21,6 +21,6 @@ func (s *SnippetBox) viewSnippet(w *htmx.ResponseWriter, r *htmx.Request) {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-	view := pages.SnippetPage{Snippet: item}
+	view := pages.SnippetPage{Snippet: item, Layout: s.layout(r)}
 	s.render(w, r, view)
 }
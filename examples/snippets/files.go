@@ -0,0 +1,8 @@
+package snippets
+
+import "embed"
+
+// Files contains the page and component templates of the application.
+//
+//go:embed html/pages/*.tmpl html/components/*.tmpl
+var Files embed.FS
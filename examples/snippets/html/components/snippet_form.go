@@ -0,0 +1,37 @@
+package components
+
+import "github.com/nisimpson/htmx"
+
+type SnippetForm struct {
+	Title   string `form:"title" validate:"required,max=100"`
+	Content string `form:"content" validate:"required"`
+	Expires int    `form:"expires" validate:"required,oneof=1 7 365" message:"This field must equal 1, 7 or 365"`
+
+	Errors    htmx.FieldErrors `form:"-"`
+	CSRFToken string           `form:"-"`
+}
+
+func (SnippetForm) TemplateName() string {
+	return "snippet_form"
+}
+
+// SnippetFormField is a single field of the snippet form, re-rendered as the
+// field is validated.
+type SnippetFormField struct {
+	SnippetForm
+	Name string
+}
+
+func (f SnippetFormField) TemplateName() string {
+	return "snippet_form_" + f.Name
+}
+
+func (f SnippetFormField) TemplateData() any {
+	return f.SnippetForm
+}
+
+// HasField returns true if the form field with the name can be validated on
+// its own.
+func (SnippetForm) HasField(name string) bool {
+	return name == "title" || name == "content"
+}
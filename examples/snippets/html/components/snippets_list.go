@@ -11,12 +11,6 @@ type SnippetsList struct {
 	Snippets []*models.Snippet
 }
 
-func (SnippetsList) TemplateFiles() []string {
-	return []string{
-		"./html/components/snippets_list.tmpl",
-	}
-}
-
 func (SnippetsList) TemplateName() string {
 	return "snippets_list"
 }
@@ -4,6 +4,14 @@ import "github.com/nisimpson/htmx/examples/snippets/html/components"
 
 type HomePage struct {
 	components.SnippetsList
+	Layout
+	Form components.SnippetForm
 }
 
 func (HomePage) TemplateName() string { return "home.tmpl" }
+
+func (h HomePage) TemplateData() any {
+	// sort the snippets list, keeping the rest of the page data.
+	h.SnippetsList = h.SnippetsList.TemplateData().(components.SnippetsList)
+	return h
+}
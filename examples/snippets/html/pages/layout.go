@@ -0,0 +1,9 @@
+package pages
+
+// Layout is the data of the base layout shared by every page.
+type Layout struct {
+	// Sent with the requests of the page to protect them from forgery.
+	CSRFToken string
+	// Allows the inline scripts and styles of the page.
+	CSPNonce string
+}
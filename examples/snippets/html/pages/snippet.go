@@ -4,6 +4,7 @@ import "github.com/nisimpson/htmx/examples/snippets/pkg/models"
 
 type SnippetPage struct {
 	*models.Snippet
+	Layout
 }
 
 func (SnippetPage) TemplateName() string { return "snippet.tmpl" }
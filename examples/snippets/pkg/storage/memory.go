@@ -3,13 +3,22 @@ package storage
 import (
 	"context"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/nisimpson/htmx/examples/snippets"
 	"github.com/nisimpson/htmx/examples/snippets/pkg/models"
+	"github.com/nisimpson/htmx/longpoll"
 )
 
+// SnippetsResource is the key of the snippets resource announced to the Notifier.
+const SnippetsResource = "snippets"
+
 type MemoryStorage struct {
+	// Notifier, if set, is signaled whenever a snippet is written.
+	Notifier longpoll.Notifier
+
+	mu       sync.RWMutex
 	counter  int
 	snippets map[string]*models.Snippet
 }
@@ -19,15 +28,25 @@ func NewMemoryStorage() *MemoryStorage {
 }
 
 func (m *MemoryStorage) CreateSnippet(ctx context.Context, data *models.Snippet) (string, error) {
+	m.mu.Lock()
 	m.counter++
 	data.ID = strconv.Itoa(m.counter)
 	data.Created = time.Now()
-	data.Expires = data.Created.Add(24 * time.Hour)
+	if data.Expires.IsZero() {
+		data.Expires = data.Created.Add(24 * time.Hour)
+	}
 	m.snippets[data.ID] = data
+	m.mu.Unlock()
+
+	if m.Notifier != nil {
+		m.Notifier.Notify(SnippetsResource)
+	}
 	return data.ID, nil
 }
 
-func (m MemoryStorage) GetSnippetWithID(ctx context.Context, id string) (*models.Snippet, error) {
+func (m *MemoryStorage) GetSnippetWithID(ctx context.Context, id string) (*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	if item, ok := m.snippets[id]; !ok {
 		return nil, snippets.ErrItemNotFound
 	} else {
@@ -35,7 +54,9 @@ func (m MemoryStorage) GetSnippetWithID(ctx context.Context, id string) (*models
 	}
 }
 
-func (m MemoryStorage) GetSnippets(ctx context.Context) ([]*models.Snippet, error) {
+func (m *MemoryStorage) GetSnippets(ctx context.Context) ([]*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	snippets := make([]*models.Snippet, 0, len(m.snippets))
 	for _, value := range m.snippets {
 		snippets = append(snippets, value)
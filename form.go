@@ -0,0 +1,526 @@
+package htmx
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxFormMemory is the number of bytes of multipart forms kept in memory by
+// DecodeForm(); the remainder of uploaded files is stored on disk.
+const MaxFormMemory = 32 << 20
+
+// timeLayouts are the layouts accepted for time.Time fields, matching the
+// values of the date and time html input types.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006-01",
+	"15:04:05",
+	"15:04",
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+// DecodeForm parses the form of the request, including multipart forms, and
+// decodes its values into the struct pointed to by dst, before validating it;
+// see DecodeValues() and Validate(). Fields missing from the form, or given a
+// blank value, are empty for the validation rules, so that "required" rejects
+// a missing number while "min=18" rejects a submitted 0. Values that cannot
+// be converted to their field type, and values breaking the validation rules,
+// are reported with FieldErrors:
+//
+//	var form struct {
+//		Title   string    `form:"title" validate:"required,max=100"`
+//		Tags    []string  `form:"tags"`
+//		Expires time.Time `form:"expires"`
+//	}
+//	if err := r.DecodeForm(&form); err != nil {
+//		var errs htmx.FieldErrors
+//		if errors.As(err, &errs) {
+//			// re-render the form with the errors
+//		}
+//	}
+func (r Request) DecodeForm(dst any) error {
+	return r.decodeForm(dst, nil)
+}
+
+// decodeForm decodes and validates the fields of the request form whose name
+// is accepted by include, or every field if include is nil.
+func (r Request) decodeForm(dst any, include func(name string) bool) error {
+	var files map[string][]*multipart.FileHeader
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(MaxFormMemory); err != nil {
+			return fmt.Errorf("htmx: parse form: %w", err)
+		}
+		files = r.MultipartForm.File
+	} else if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("htmx: parse form: %w", err)
+	}
+
+	errs := FieldErrors{}
+	decoded, err := decodeForm(r.Form, files, dst)
+	if err != nil {
+		var decodeErrs FieldErrors
+		if !errors.As(err, &decodeErrs) {
+			return err
+		}
+		for name, problem := range decodeErrs {
+			if include == nil || include(name) {
+				errs.add(name, problem)
+			}
+		}
+	}
+	for name, problem := range validateForm(dst, include, decoded) {
+		errs.add(name, problem)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// DecodeValues decodes the form values into the struct pointed to by dst.
+// Fields are named by their "form" tag, or by their name if untagged; fields
+// tagged with "-" are skipped:
+//
+//	type Address struct {
+//		Street string `form:"street"`
+//	}
+//
+//	type Profile struct {
+//		Name      string    `form:"name"`
+//		Age       int       `form:"age"`
+//		Subscribe bool      `form:"subscribe"`
+//		Address   Address   `form:"address"`   // "address.street"
+//		Emails    []string  `form:"emails"`    // repeated "emails" values
+//		Contacts  []Address `form:"contacts"`  // "contacts[0].street"
+//		Birthday  time.Time `form:"birthday"`  // <input type="date">
+//		Internal  string    `form:"-"`
+//	}
+//
+// Supported field types are strings, booleans ("on" is true, as sent by
+// checkboxes), numbers, time.Time, types implementing
+// encoding.TextUnmarshaler, structs, and pointers and slices of those.
+// Fields without a value are left untouched, as are blank values of
+// non-string fields. Values that cannot be converted are reported with
+// FieldErrors, keyed by the name of the value.
+func DecodeValues(values url.Values, dst any) error {
+	_, err := decodeForm(values, nil, dst)
+	return err
+}
+
+// decodeForm decodes the form into dst, returning what the form gave its
+// fields.
+func decodeForm(values url.Values, files map[string][]*multipart.FileHeader, dst any) (*decodedForm, error) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("htmx: decode form: destination must be a non-nil pointer to a struct, got %T", dst)
+	}
+
+	d := formDecoder{
+		values:      values,
+		files:       files,
+		errs:        FieldErrors{},
+		decodedForm: decodedForm{filled: map[fieldKey]bool{}, names: map[fieldKey]string{}},
+	}
+	if err := d.decodeStruct(v.Elem(), ""); err != nil {
+		return nil, err
+	}
+	if len(d.errs) > 0 {
+		return &d.decodedForm, d.errs
+	}
+	return &d.decodedForm, nil
+}
+
+type formDecoder struct {
+	values url.Values
+	files  map[string][]*multipart.FileHeader
+	errs   FieldErrors
+	decodedForm
+}
+
+// decodedForm records what the form gave the fields of the destination, so
+// that validation reports problems the way the form names the fields.
+type decodedForm struct {
+	// The fields given a value that is not blank.
+	filled map[fieldKey]bool
+	// The form names of the slice elements decoded from indexed keys, which
+	// differ from their positions when the indexes are sparse.
+	names map[fieldKey]string
+}
+
+// fieldKey identifies a field by address and type, since a struct and its
+// first field share the same address.
+type fieldKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+func fieldKeyOf(v reflect.Value) (fieldKey, bool) {
+	if !v.CanAddr() {
+		return fieldKey{}, false
+	}
+	return fieldKey{addr: v.Addr().Pointer(), typ: v.Type()}, true
+}
+
+// formFields calls fn with each decoded field of the struct and its form name,
+// flattening embedded structs without a tag.
+func formFields(v reflect.Value, prefix string, fn func(field reflect.Value, sf reflect.StructField, key string) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("form")
+		if tag == "-" || (!sf.IsExported() && !sf.Anonymous) {
+			continue
+		}
+
+		field := v.Field(i)
+		if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+			if err := formFields(field, prefix, fn); err != nil {
+				return err
+			}
+			continue
+		} else if !sf.IsExported() {
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = sf.Name
+		}
+		if err := fn(field, sf, prefix+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *formDecoder) decodeStruct(v reflect.Value, prefix string) error {
+	return formFields(v, prefix, func(field reflect.Value, _ reflect.StructField, key string) error {
+		return d.decodeField(field, key)
+	})
+}
+
+func (d *formDecoder) decodeField(v reflect.Value, key string) error {
+	t := v.Type()
+	switch {
+	case t == fileHeaderType:
+		if files := d.files[key]; len(files) > 0 {
+			v.Set(reflect.ValueOf(files[0]))
+			d.fill(v)
+		}
+		return nil
+	case t.Kind() == reflect.Slice && t.Elem() == fileHeaderType:
+		if files := d.files[key]; len(files) > 0 {
+			v.Set(reflect.ValueOf(files))
+			d.fill(v)
+		}
+		return nil
+	case isScalar(t):
+		if values, ok := d.values[key]; ok && len(values) > 0 {
+			d.decodeScalar(v, key, values[0])
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		if !d.hasValues(key) || (isScalar(t.Elem()) && t.Elem().Kind() != reflect.String && strings.TrimSpace(d.values.Get(key)) == "") {
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return d.decodeField(v.Elem(), key)
+	case reflect.Struct:
+		return d.decodeStruct(v, key+".")
+	case reflect.Slice:
+		return d.decodeSlice(v, key)
+	}
+	return fmt.Errorf("htmx: decode form: unsupported type %s of field %q", t, key)
+}
+
+// isScalar returns true if values of the type are decoded from a single form
+// value.
+func isScalar(t reflect.Type) bool {
+	if t == timeType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// hasValues returns true if the form has a value for the key, or for fields
+// nested within the key.
+func (d *formDecoder) hasValues(key string) bool {
+	if _, ok := d.values[key]; ok {
+		return true
+	}
+	if _, ok := d.files[key]; ok {
+		return true
+	}
+	for name := range d.values {
+		if strings.HasPrefix(name, key+".") || strings.HasPrefix(name, key+"[") {
+			return true
+		}
+	}
+	for name := range d.files {
+		if strings.HasPrefix(name, key+".") || strings.HasPrefix(name, key+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeSlice decodes the repeated values of the key into slices of scalars,
+// and the indexed keys "key[i]" into other slices. Indexes only determine the
+// order of the elements, so that sparse indexes do not allocate large slices.
+func (d *formDecoder) decodeSlice(v reflect.Value, key string) error {
+	elem := v.Type().Elem()
+	if isScalar(elem) {
+		values, ok := d.values[key]
+		if !ok {
+			return nil
+		}
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			d.decodeScalar(slice.Index(i), fmt.Sprintf("%s[%d]", key, i), value)
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	indexes := d.indexes(key)
+	if len(indexes) == 0 {
+		return nil
+	}
+	slice := reflect.MakeSlice(v.Type(), len(indexes), len(indexes))
+	for i, index := range indexes {
+		name := fmt.Sprintf("%s[%d]", key, index)
+		if err := d.decodeField(slice.Index(i), name); err != nil {
+			return err
+		}
+		// the elements keep their address once the slice is set.
+		if elem, ok := fieldKeyOf(slice.Index(i)); ok {
+			d.names[elem] = name
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+// indexes returns the sorted indexes i of the "key[i]" form names.
+func (d *formDecoder) indexes(key string) []int {
+	seen := make(map[int]bool)
+	add := func(name string) {
+		rest, ok := strings.CutPrefix(name, key+"[")
+		if !ok {
+			return
+		}
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return
+		}
+		if index, err := strconv.Atoi(rest[:end]); err == nil && index >= 0 {
+			seen[index] = true
+		}
+	}
+	for name := range d.values {
+		add(name)
+	}
+	for name := range d.files {
+		add(name)
+	}
+
+	indexes := make([]int, 0, len(seen))
+	for index := range seen {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// fill records that the field received a value.
+func (d *formDecoder) fill(v reflect.Value) {
+	if key, ok := fieldKeyOf(v); ok {
+		d.filled[key] = true
+	}
+}
+
+// decodeScalar converts the value into the field, recording a field error if
+// the value is invalid.
+func (d *formDecoder) decodeScalar(v reflect.Value, key, value string) {
+	if strings.TrimSpace(value) == "" {
+		if v.Kind() != reflect.String {
+			return
+		}
+	} else {
+		d.fill(v)
+	}
+
+	if v.Type() == timeType {
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return
+			}
+		}
+		d.errs.add(key, "Must be a valid date or time")
+		return
+	} else if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(value)); err != nil {
+			d.errs.add(key, "Must be a valid value")
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		if value == "on" {
+			v.SetBool(true)
+		} else if b, err := strconv.ParseBool(value); err == nil {
+			v.SetBool(b)
+		} else {
+			d.errs.add(key, "Must be true or false")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, err := strconv.ParseInt(value, 10, v.Type().Bits()); err == nil {
+			v.SetInt(n)
+		} else {
+			d.errs.add(key, "Must be a whole number")
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseUint(value, 10, v.Type().Bits()); err == nil {
+			v.SetUint(n)
+		} else {
+			d.errs.add(key, "Must be a positive whole number")
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(value, v.Type().Bits()); err == nil {
+			v.SetFloat(n)
+		} else {
+			d.errs.add(key, "Must be a number")
+		}
+	}
+}
+
+// WriteFormErrors renders the form component, typically displaying the field
+// errors returned by DecodeForm(), with a "422 Unprocessable Entity" status
+// code. When set, the target and swap are sent with the "HX-Retarget" and
+// "HX-Reswap" headers, so that the form is replaced regardless of the target
+// and swap of the element that submitted it.
+//
+// htmx does not swap the content of error responses by default. The client
+// must be configured to swap "422" responses, for example with:
+//
+//	document.body.addEventListener("htmx:beforeSwap", function (evt) {
+//		if (evt.detail.xhr.status === 422) {
+//			evt.detail.shouldSwap = true;
+//			evt.detail.isError = false;
+//		}
+//	});
+func WriteFormErrors(w *ResponseWriter, form Component, target string, swap Swap) error {
+	if target != "" {
+		if err := w.SetRetargetHeader(target); err != nil {
+			return err
+		}
+	}
+	if swap != (Swap{}) {
+		if err := w.SetReswapHeader(swap); err != nil {
+			return err
+		}
+	}
+	WriteComponent(w, form, http.StatusUnprocessableEntity)
+	return nil
+}
+
+// FieldValidator is a handler validating a single field of a form, as the user
+// fills it in, with the rules used when the form is submitted; see
+// DecodeForm(). The field is named by the "HX-Trigger-Name" header, that is
+// the name of the element triggering the request. The form is decoded, but
+// only problems with the field are reported to Render, whose fragment is
+// written with a 200 OK status code, so that htmx swaps it whether the field
+// is valid or not. For example:
+//
+//	mux.Handle("/signup/validate", htmx.HTMX(htmx.FieldValidator{
+//		Form: func() any { return &SignUp{} },
+//		Render: func(r *htmx.Request, form any, name, problem string) htmx.Component {
+//			return field{Name: name, Value: r.FormValue(name), Problem: problem}
+//		},
+//	}))
+//
+// along with the markup, where the field is replaced when changed:
+//
+//	<form hx-post="/signup">
+//	    <div hx-target="this" hx-swap="outerHTML">
+//	        <input name="email" hx-post="/signup/validate" hx-trigger="change">
+//	    </div>
+//	</form>
+//
+// Requests without the header, or whose form cannot be parsed, are answered
+// with "400 Bad Request".
+type FieldValidator struct {
+	// Returns a pointer to a new struct the form is decoded into.
+	Form func() any
+	// Renders the fragment of the field with the name, given the decoded form
+	// and the problem with the field; the problem is empty if the field is
+	// valid. A nil component is answered with "400 Bad Request", allowing
+	// unknown fields to be refused.
+	Render func(r *Request, form any, name, problem string) Component
+}
+
+// ServeHTMX validates the field that triggered the request, and writes its
+// fragment.
+func (v FieldValidator) ServeHTMX(w *ResponseWriter, r *Request) {
+	name := r.HTMXTriggerName()
+	if name == "" {
+		http.Error(w, "missing "+HeaderHXTriggerName+" header", http.StatusBadRequest)
+		return
+	}
+
+	form := v.Form()
+	var problem string
+	err := r.decodeForm(form, func(field string) bool { return field == name })
+
+	var errs FieldErrors
+	if errors.As(err, &errs) {
+		problem = errs[name]
+	} else if err != nil {
+		// the error may describe the destination or the body; keep it private.
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	fragment := v.Render(r, form, name, problem)
+	if fragment == nil {
+		http.Error(w, "unknown field "+name, http.StatusBadRequest)
+		return
+	}
+	WriteComponent(w, fragment, http.StatusOK)
+}
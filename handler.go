@@ -21,16 +21,71 @@ func (f HandlerFunc) ServeHTMX(w *ResponseWriter, r *Request) {
 	f(w, r)
 }
 
+// Option configures the behavior of a handler wrapped by HTMX().
+type Option func(*config)
+
+type config struct {
+	errorHandler   ErrorHandler
+	redirectPolicy *RedirectPolicy
+}
+
+// WithErrorHandler sets the handler responding to errors that occur while
+// rendering components with WriteComponent().
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(c *config) {
+		c.errorHandler = handler
+	}
+}
+
+// WithRedirectPolicy sets the policy checking the urls of the "HX-Redirect",
+// "HX-Location", "HX-Push-Url" and "HX-Replace-Url" headers, and of redirects
+// sent with Redirect(). The policy is attached to the request context. Without
+// this option, the header setters of the response writer accept any url.
+func WithRedirectPolicy(policy RedirectPolicy) Option {
+	return func(c *config) {
+		c.redirectPolicy = &policy
+	}
+}
+
 // HTMX wraps the htmx handler into a standard library http handler function,
-// which can be used by a Go http muxer.
-func HTMX(handler Handler) http.HandlerFunc {
+// which can be used by a Go http muxer. The trigger event collector of the
+// response writer is attached to the request context, so that Trigger() may
+// be called by any code receiving the context. When the request context
+// already carries a collector, such as when HTMX() handlers are nested, the
+// collector is reused so that the events of every handler are merged into the
+// same headers.
+func HTMX(handler Handler, opts ...Option) http.HandlerFunc {
+	cfg := config{errorHandler: DefaultErrorHandler}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		handler.ServeHTMX(NewResponseWriter(w), NewRequest(r))
+		writer := NewResponseWriter(w)
+		ctx := r.Context()
+		if triggers, ok := TriggersFromContext(ctx); ok {
+			writer.state.triggers = triggers
+		} else if parent, ok := w.(*ResponseWriter); ok && parent.Triggers() != nil {
+			writer.state.triggers = parent.Triggers()
+		}
+		ctx = ContextWithTriggers(ctx, writer.Triggers())
+		if cfg.redirectPolicy != nil {
+			ctx = ContextWithRedirectPolicy(ctx, *cfg.redirectPolicy)
+		}
+		r = r.WithContext(ctx)
+		request := NewRequest(r)
+		writer.state.request = request
+		writer.state.errorHandler = cfg.errorHandler
+		handler.ServeHTMX(writer, request)
+
+		// ensure collected trigger events are sent when the handler did
+		// not write a response body.
+		writer.writeTriggers()
 	}
 }
 
 // HTMXFunc wraps the htmx handler function into a standard library http handler function,
 // which can be used by a Go http muxer.
-func HTMXFunc(f HandlerFunc) http.HandlerFunc {
-	return HTMX(f)
+func HTMXFunc(f HandlerFunc, opts ...Option) http.HandlerFunc {
+	return HTMX(f, opts...)
 }
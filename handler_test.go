@@ -0,0 +1,74 @@
+package htmx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTMXNestedTriggers(t *testing.T) {
+	tests := []struct {
+		name  string
+		outer func(w *ResponseWriter, r *Request, inner http.Handler)
+		inner HandlerFunc
+		want  map[string]string
+	}{
+		{
+			name: "events before and after the inner handler",
+			outer: func(w *ResponseWriter, r *Request, inner http.Handler) {
+				w.SetTriggerHeader(TriggerEvents("outer"))
+				inner.ServeHTTP(w, r.Request)
+				w.SetTriggerHeader(TriggerEvents("after"))
+			},
+			inner: func(w *ResponseWriter, r *Request) {
+				w.SetTriggerHeader(TriggerEvents("inner"))
+			},
+			want: map[string]string{HeaderHXTrigger: "outer,inner,after"},
+		},
+		{
+			name: "inner handler writes the body",
+			outer: func(w *ResponseWriter, r *Request, inner http.Handler) {
+				Trigger(r.Context(), TriggerEvents("outer"))
+				inner.ServeHTTP(w, r.Request)
+			},
+			inner: func(w *ResponseWriter, r *Request) {
+				Trigger(r.Context(), TriggerEventsWithContext(map[string]any{"inner": 1}))
+				TriggerAfterSwap(r.Context(), TriggerEvents("swapped"))
+				w.Write([]byte("ok"))
+			},
+			want: map[string]string{
+				HeaderHXTrigger:          `{"outer":{},"inner":1}`,
+				HeaderHXTriggerAfterSwap: "swapped",
+			},
+		},
+		{
+			name: "inner handler without the outer context",
+			outer: func(w *ResponseWriter, r *Request, inner http.Handler) {
+				w.SetTriggerHeader(TriggerEvents("outer"))
+				inner.ServeHTTP(w, r.WithContext(context.Background()))
+			},
+			inner: func(w *ResponseWriter, r *Request) {
+				Trigger(r.Context(), TriggerEvents("inner"))
+			},
+			want: map[string]string{HeaderHXTrigger: "outer,inner"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := HTMX(tt.inner)
+			outer := HTMXFunc(func(w *ResponseWriter, r *Request) {
+				tt.outer(w, r, inner)
+			})
+
+			rec := httptest.NewRecorder()
+			outer.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			for _, header := range []string{HeaderHXTrigger, HeaderHXTriggerAfterSwap, HeaderHXTriggerAfterSettle} {
+				if got := rec.Header().Get(header); got != tt.want[header] {
+					t.Errorf("%s = %q, want %q", header, got, tt.want[header])
+				}
+			}
+		})
+	}
+}
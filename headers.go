@@ -28,7 +28,7 @@ const (
 
 	// Request header containing the name of the element that triggered the request.
 	//   - https://htmx.org/docs/#requests
-	HeaderHXTriggerName = "HX-TriggerName"
+	HeaderHXTriggerName = "HX-Trigger-Name"
 
 	// Request header containing the id of the target element.
 	//   - https://htmx.org/docs/#requests
@@ -88,4 +88,9 @@ const (
 	// settle step.
 	//   - https://htmx.org/headers/hx-trigger/
 	HeaderHXTriggerAfterSettle = "HX-Trigger-After-Settle"
+
+	// Suffix of the companion request header htmx sends when a header value
+	// contains characters that cannot be transmitted as is. When present with
+	// the value "true", the original header value is URI encoded.
+	headerSuffixURIAutoEncoded = "-URI-AutoEncoded"
 )
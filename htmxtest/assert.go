@@ -0,0 +1,250 @@
+package htmxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nisimpson/htmx"
+)
+
+// AssertStatus reports an error if the status code of the response differs
+// from want.
+func (r *Recorder) AssertStatus(t testing.TB, want int) {
+	t.Helper()
+	if r.Code != want {
+		t.Errorf("htmxtest: status is %d %s, want %d %s", r.Code, http.StatusText(r.Code), want, http.StatusText(want))
+	}
+}
+
+// AssertHeader reports an error if the response header, as sent, differs from
+// want. An empty want asserts that the header is absent.
+func (r *Recorder) AssertHeader(t testing.TB, key, want string) {
+	t.Helper()
+	got, ok := r.sentHeader()[http.CanonicalHeaderKey(key)]
+	switch {
+	case want == "" && ok:
+		t.Errorf("htmxtest: %s header is %q, want no header", key, strings.Join(got, ", "))
+	case want != "" && !ok:
+		t.Errorf("htmxtest: %s header is missing, want %q", key, want)
+	case want != "" && got[0] != want:
+		t.Errorf("htmxtest: %s header is %q, want %q", key, got[0], want)
+	}
+}
+
+// AssertTriggers reports an error if the names of the events triggered during
+// the phase differ from want, in order.
+func (r *Recorder) AssertTriggers(t testing.TB, phase htmx.TriggerPhase, want ...string) {
+	t.Helper()
+	events, err := r.Triggers(phase)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	got := make([]string, 0, len(events))
+	for _, event := range events {
+		got = append(got, event.Name)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("htmxtest: %s events differ (-want +got):\n%s", triggerHeader(phase), Diff(strings.Join(want, "\n"), strings.Join(got, "\n")))
+	}
+}
+
+// AssertTrigger reports an error if the event was not triggered during the
+// phase, or if its detail differs from the JSON encoding of detail. A nil
+// detail only asserts that the event was triggered.
+func (r *Recorder) AssertTrigger(t testing.TB, phase htmx.TriggerPhase, name string, detail any) {
+	t.Helper()
+	events, err := r.Triggers(phase)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, event := range events {
+		if event.Name != name {
+			continue
+		} else if detail == nil {
+			return
+		}
+		want, err := json.Marshal(detail)
+		if err != nil {
+			t.Errorf("htmxtest: cannot encode the detail of event %q: %v", name, err)
+			return
+		}
+		got := event.Detail
+		if got == nil {
+			got = json.RawMessage("{}")
+		}
+		if diff := diffJSON(want, got); diff != "" {
+			t.Errorf("htmxtest: detail of event %q differs (-want +got):\n%s", name, diff)
+		}
+		return
+	}
+
+	names := make([]string, 0, len(events))
+	for _, event := range events {
+		names = append(names, fmt.Sprintf("%q", event.Name))
+	}
+	if len(names) == 0 {
+		t.Errorf("htmxtest: event %q was not triggered, %s header is missing", name, triggerHeader(phase))
+	} else {
+		t.Errorf("htmxtest: event %q was not triggered, %s header has %s", name, triggerHeader(phase), strings.Join(names, ", "))
+	}
+}
+
+// AssertLocation reports an error if the "HX-Location" header is missing or
+// differs from want.
+func (r *Recorder) AssertLocation(t testing.TB, want htmx.Location) {
+	t.Helper()
+	got, ok, err := r.Location()
+	switch {
+	case err != nil:
+		t.Error(err)
+		return
+	case !ok:
+		t.Errorf("htmxtest: %s header is missing", htmx.HeaderHXLocation)
+		return
+	}
+
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		t.Errorf("htmxtest: cannot encode the wanted location: %v", err)
+		return
+	}
+	gotJSON, _ := json.Marshal(got)
+	if diff := diffJSON(wantJSON, gotJSON); diff != "" {
+		t.Errorf("htmxtest: %s header differs (-want +got):\n%s", htmx.HeaderHXLocation, diff)
+	}
+}
+
+// AssertReswap reports an error if the "HX-Reswap" header is missing or
+// differs from want. Specifications are compared once parsed, so that the
+// order of the modifiers, or the unit of the delays, does not matter.
+func (r *Recorder) AssertReswap(t testing.TB, want htmx.Swap) {
+	t.Helper()
+	got, ok, err := r.Reswap()
+	switch {
+	case err != nil:
+		t.Error(err)
+	case !ok:
+		t.Errorf("htmxtest: %s header is missing, want %q", htmx.HeaderHXReswap, want)
+	case got.String() != want.String():
+		t.Errorf("htmxtest: %s header differs (-want +got):\n%s", htmx.HeaderHXReswap,
+			Diff(strings.Join(strings.Fields(want.String()), "\n"), strings.Join(strings.Fields(got.String()), "\n")))
+	}
+}
+
+// AssertBody reports an error if the response body differs from want.
+func (r *Recorder) AssertBody(t testing.TB, want string) {
+	t.Helper()
+	if got := r.Body.String(); got != want {
+		t.Errorf("htmxtest: body differs (-want +got):\n%s", Diff(want, got))
+	}
+}
+
+// AssertBodyContains reports an error if the response body does not contain
+// every substring.
+func (r *Recorder) AssertBodyContains(t testing.TB, substrings ...string) {
+	t.Helper()
+	body := r.Body.String()
+	for _, s := range substrings {
+		if !strings.Contains(body, s) {
+			t.Errorf("htmxtest: body does not contain %q, body is:\n%s", s, body)
+		}
+	}
+}
+
+// diffJSON returns the difference between the indented JSON values, or an
+// empty string if the values are equal. Object keys are sorted, so that their
+// order does not matter.
+func diffJSON(want, got []byte) string {
+	want, got = normalizeJSON(want), normalizeJSON(got)
+	if string(want) == string(got) {
+		return ""
+	}
+	return Diff(string(want), string(got))
+}
+
+func normalizeJSON(data []byte) []byte {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []byte(indentJSON(data))
+	}
+	normalized, _ := json.MarshalIndent(v, "", "  ")
+	return normalized
+}
+
+// Diff returns a line by line difference between want and got, with removed
+// lines prefixed by "-" and added lines by "+". Long runs of unchanged lines
+// are elided.
+func Diff(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	const context = 3
+	var out strings.Builder
+	elided := false
+	for k, l := range lines {
+		if l.op == ' ' && !nearChange(k, context, func(k int) bool { return k >= 0 && k < len(lines) && lines[k].op != ' ' }) {
+			if !elided {
+				out.WriteString("  ...\n")
+				elided = true
+			}
+			continue
+		}
+		elided = false
+		out.WriteByte(l.op)
+		out.WriteByte(' ')
+		out.WriteString(l.text)
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
+
+// nearChange returns true if a changed line is within distance of line k.
+func nearChange(k, distance int, changed func(int) bool) bool {
+	for d := -distance; d <= distance; d++ {
+		if changed(k + d) {
+			return true
+		}
+	}
+	return false
+}
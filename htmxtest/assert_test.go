@@ -0,0 +1,75 @@
+package htmxtest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		got  string
+		diff string
+	}{
+		{
+			name: "equal",
+			want: "a\nb",
+			got:  "a\nb",
+			diff: "  ...\n",
+		},
+		{
+			name: "changed line",
+			want: "a\nb\nc",
+			got:  "a\nB\nc",
+			diff: "  a\n- b\n+ B\n  c\n",
+		},
+		{
+			name: "added line",
+			want: "a\nc",
+			got:  "a\nb\nc",
+			diff: "  a\n+ b\n  c\n",
+		},
+		{
+			name: "removed line",
+			want: "a\nb\nc",
+			got:  "a\nc",
+			diff: "  a\n- b\n  c\n",
+		},
+		{
+			name: "empty want",
+			want: "",
+			got:  "a",
+			diff: "- \n+ a\n",
+		},
+		{
+			name: "long runs elided",
+			want: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13",
+			got:  "1\n2\n3\n4\n5\n6\nseven\n8\n9\n10\n11\n12\n13",
+			diff: "  ...\n  4\n  5\n  6\n- 7\n+ seven\n  8\n  9\n  10\n  ...\n",
+		},
+		{
+			name: "separate changes",
+			want: strings.Repeat("x\n", 10) + "a\n" + strings.Repeat("y\n", 10) + "b",
+			got:  strings.Repeat("x\n", 10) + "A\n" + strings.Repeat("y\n", 10) + "B",
+			diff: "  ...\n  x\n  x\n  x\n- a\n+ A\n  y\n  y\n  y\n  ...\n  y\n  y\n  y\n- b\n+ B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.want, tt.got); got != tt.diff {
+				t.Errorf("Diff() =\n%s\nwant\n%s", got, tt.diff)
+			}
+		})
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	if diff := diffJSON([]byte(`{"b":1,"a":[1,2]}`), []byte(`{"a": [1, 2], "b": 1}`)); diff != "" {
+		t.Errorf("diffJSON() of equivalent documents =\n%s\nwant none", diff)
+	}
+	if diff := diffJSON([]byte(`{"a":1}`), []byte(`{"a":2}`)); !strings.Contains(diff, "-   \"a\": 1\n+   \"a\": 2\n") {
+		t.Errorf("diffJSON() =\n%s\nwant the changed member", diff)
+	}
+}
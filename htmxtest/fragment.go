@@ -0,0 +1,262 @@
+package htmxtest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nisimpson/htmx"
+)
+
+// RenderFragment renders the component and parses the html it produced. The
+// test fails immediately if the component fails to render, or renders
+// malformed html. For example:
+//
+//	frag := htmxtest.RenderFragment(t, components.SnippetsList(snippets))
+//	frag.AssertText(t, "#snippet-3 td", "O snail")
+func RenderFragment(t testing.TB, component htmx.Component) *Node {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := component.RenderHTMX(&buf); err != nil {
+		t.Fatalf("htmxtest: cannot render component: %v", err)
+	}
+	return MustParseFragment(t, buf.String())
+}
+
+// Fragment parses the html of the response body. The test fails immediately
+// if the body is malformed.
+func (r *Recorder) Fragment(t testing.TB) *Node {
+	t.Helper()
+	return MustParseFragment(t, r.Body.String())
+}
+
+// MustParseFragment parses the html. The test fails immediately if the html
+// is malformed.
+func MustParseFragment(t testing.TB, s string) *Node {
+	t.Helper()
+	root, err := ParseHTML(s)
+	if err != nil {
+		t.Fatalf("%v, html is:\n%s", err, s)
+	}
+	return root
+}
+
+// AssertExists reports an error if no element matches the selector.
+func (n *Node) AssertExists(t testing.TB, selector string) {
+	t.Helper()
+	n.query(t, selector)
+}
+
+// AssertMissing reports an error if any element matches the selector.
+func (n *Node) AssertMissing(t testing.TB, selector string) {
+	t.Helper()
+	s, err := CompileSelector(selector)
+	if err != nil {
+		t.Error(err)
+	} else if matches := s.QueryAll(n); len(matches) > 0 {
+		t.Errorf("htmxtest: selector %q matches %s, want none:\n%s", selector, countElements(len(matches)), outline(matches))
+	}
+}
+
+// AssertCount reports an error if the number of elements matching the
+// selector differs from want.
+func (n *Node) AssertCount(t testing.TB, selector string, want int) {
+	t.Helper()
+	s, err := CompileSelector(selector)
+	if err != nil {
+		t.Error(err)
+	} else if matches := s.QueryAll(n); len(matches) != want {
+		t.Errorf("htmxtest: selector %q matches %s, want %d:\n%s", selector, countElements(len(matches)), want, outline(matches))
+	}
+}
+
+// AssertText reports an error unless an element matching the selector has the
+// text, once whitespace is collapsed as Text() does.
+func (n *Node) AssertText(t testing.TB, selector, want string) {
+	t.Helper()
+	matches := n.query(t, selector)
+	if len(matches) == 0 {
+		return
+	}
+
+	want = strings.Join(strings.Fields(want), " ")
+	texts := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if text := match.Text(); text == want {
+			return
+		} else {
+			texts = append(texts, fmt.Sprintf("%q", text))
+		}
+	}
+	t.Errorf("htmxtest: no element matching %q has text %q, found:\n\t%s", selector, want, strings.Join(texts, "\n\t"))
+}
+
+// AssertAttr reports an error unless an element matching the selector has the
+// attribute set to want.
+func (n *Node) AssertAttr(t testing.TB, selector, name, want string) {
+	t.Helper()
+	matches := n.query(t, selector)
+	if len(matches) == 0 {
+		return
+	}
+
+	values := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if value, ok := match.Attr(name); !ok {
+			values = append(values, "no attribute")
+		} else if value == want {
+			return
+		} else {
+			values = append(values, fmt.Sprintf("%q", value))
+		}
+	}
+	t.Errorf("htmxtest: no element matching %q has attribute %s=%q, found:\n\t%s", selector, name, want, strings.Join(values, "\n\t"))
+}
+
+// AssertOOBTargets reports an error unless every element of the fragment
+// marked with "hx-swap-oob", including those within the content of templates
+// as htmx processes them, targets an element of the page: the element with
+// the same id when the attribute only holds a swap style, or the elements
+// matching the selector following the swap style otherwise. Templates marked
+// with "hx-swap-oob" are reported as well, since their content is not among
+// the children swapped by htmx. A nil page checks the targets against the
+// fragment itself.
+func (n *Node) AssertOOBTargets(t testing.TB, page *Node) {
+	t.Helper()
+	if page == nil {
+		page = n
+	}
+
+	for _, oob := range oobElements(n) {
+		value, _ := oob.Attr("hx-swap-oob")
+		if oob.Data == "template" {
+			t.Errorf("htmxtest: out of band template swaps no content, mark the elements of its content instead:\n%s", outline([]*Node{oob}))
+			continue
+		}
+		target := ""
+		if _, selector, ok := strings.Cut(value, ":"); ok {
+			target = selector
+		} else if id := oob.ID(); id != "" {
+			target = "#" + cssEscape(id)
+		} else {
+			t.Errorf("htmxtest: out of band element has no id, and hx-swap-oob=%q has no target:\n%s", value, outline([]*Node{oob}))
+			continue
+		}
+
+		s, err := CompileSelector(target)
+		if err != nil {
+			t.Errorf("htmxtest: out of band element has an invalid target: %v", err)
+		} else if len(s.QueryAll(page)) == 0 {
+			t.Errorf("htmxtest: out of band target %q does not exist:\n%s", target, outline([]*Node{oob}))
+		}
+	}
+}
+
+// oobElements returns the elements marked with "hx-swap-oob", within the node
+// and the content of its templates.
+func oobElements(n *Node) []*Node {
+	var elements []*Node
+	n.walk(func(n *Node) {
+		if _, ok := n.Attr("hx-swap-oob"); ok && n.Type == ElementNode {
+			elements = append(elements, n)
+		}
+		if n.Content != nil {
+			elements = append(elements, oobElements(n.Content)...)
+		}
+	})
+	return elements
+}
+
+// query returns the elements matching the selector, reporting an error if the
+// selector is malformed or matches nothing.
+func (n *Node) query(t testing.TB, selector string) []*Node {
+	t.Helper()
+	s, err := CompileSelector(selector)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	matches := s.QueryAll(n)
+	if len(matches) == 0 {
+		t.Errorf("htmxtest: selector %q matches nothing%s", selector, explainNoMatch(n, s))
+	}
+	return matches
+}
+
+// explainNoMatch describes the longest leading part of the selector matching
+// some elements, to locate the part of the selector that fails.
+func explainNoMatch(root *Node, s Selector) string {
+	if len(s.list) != 1 {
+		return ", html is:\n" + outline(root.Children)
+	}
+
+	c := s.list[0]
+	for i := len(c.compounds) - 1; i > 0; i-- {
+		prefix := complexSelector{compounds: c.compounds[:i], combinators: c.combinators[:i-1]}
+		var matches []*Node
+		for _, child := range root.Children {
+			child.walk(func(n *Node) {
+				if n.Type == ElementNode && prefix.match(n, i-1) {
+					matches = append(matches, n)
+				}
+			})
+		}
+		if len(matches) > 0 {
+			return fmt.Sprintf(", although %q matches %s:\n%s", prefix.String(), countElements(len(matches)), outline(matches))
+		}
+	}
+	return ", html is:\n" + outline(root.Children)
+}
+
+// String returns the source of the complex selector.
+func (c complexSelector) String() string {
+	var b strings.Builder
+	for i, compound := range c.compounds {
+		if i > 0 {
+			if combinator := c.combinators[i-1]; combinator == ' ' {
+				b.WriteByte(' ')
+			} else {
+				b.WriteString(" " + string(combinator) + " ")
+			}
+		}
+		b.WriteString(compound.source)
+	}
+	return b.String()
+}
+
+func countElements(n int) string {
+	if n == 1 {
+		return "1 element"
+	}
+	return fmt.Sprintf("%d elements", n)
+}
+
+// outline renders the nodes for failure messages, truncating long html.
+func outline(nodes []*Node) string {
+	const limit = 2000
+	var b strings.Builder
+	for _, n := range nodes {
+		if s := strings.TrimSpace(n.HTML()); s != "" {
+			b.WriteString("\t" + s + "\n")
+		}
+	}
+	if s := b.String(); len(s) > limit {
+		return s[:limit] + "…\n"
+	}
+	return b.String()
+}
+
+// cssEscape escapes the characters of the identifier that cannot appear in a
+// CSS selector as is.
+func cssEscape(ident string) string {
+	var b strings.Builder
+	for i := 0; i < len(ident); i++ {
+		c := ident[i]
+		if !(c == '-' || c == '_' || c >= 0x80 || isLetter(c) || '0' <= c && c <= '9') {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
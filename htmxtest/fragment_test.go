@@ -0,0 +1,278 @@
+package htmxtest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nisimpson/htmx"
+)
+
+const oobPage = `<main>
+	<form id="snippet-form"></form>
+	<span id="counter">2</span>
+	<div id="flash"></div>
+	<table id="snippets"><tbody><tr id="snippet-1"><td>one</td></tr></tbody></table>
+	<ul id="list"><li>one</li></ul>
+	<input id="search">
+	<div id="editor"></div>
+</main>`
+
+func rawHTML(s string) htmx.Component {
+	return htmx.ComponentFunc(func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	})
+}
+
+func TestWithOOB(t *testing.T) {
+	tests := []struct {
+		name     string
+		oob      htmx.OOBComponent
+		element  string
+		swapOOB  string
+		children string
+		template bool
+	}{
+		{
+			name:     "element swapped by id",
+			oob:      htmx.OOB(rawHTML(`<span id="counter">3</span>`), htmx.SwapOuterHTML, ""),
+			element:  "span",
+			swapOOB:  "true",
+			children: "3",
+		},
+		{
+			name:     "element swapped into a target",
+			oob:      htmx.OOB(rawHTML(`<span id="counter" class="new">3</span>`), htmx.SwapOuterHTML, "#counter"),
+			element:  "span",
+			swapOOB:  "outerHTML:#counter",
+			children: "3",
+		},
+		{
+			name:     "children swapped by id",
+			oob:      htmx.OOB(rawHTML(`<div id="flash"><p>Saved</p></div>`), htmx.SwapInnerHTML, ""),
+			element:  "div",
+			swapOOB:  "innerHTML",
+			children: "<p>Saved</p>",
+		},
+		{
+			name:     "content wrapped in a div",
+			oob:      htmx.OOB(rawHTML(`<p>Saved</p> <p>Again</p>`), htmx.SwapInnerHTML, "#flash"),
+			element:  "div",
+			swapOOB:  "innerHTML:#flash",
+			children: "<p>Saved</p> <p>Again</p>",
+		},
+		{
+			name:     "list items wrapped in a div",
+			oob:      htmx.OOB(rawHTML(`<li>two</li><li>three</li>`), htmx.SwapBeforeEnd, "#list"),
+			element:  "div",
+			swapOOB:  "beforeend:#list",
+			children: "<li>two</li><li>three</li>",
+		},
+		{
+			name:     "table rows wrapped in their section",
+			oob:      htmx.OOB(rawHTML("\n<tr id=\"snippet-2\"><td>two</td></tr>"), htmx.SwapBeforeEnd, "#snippets tbody"),
+			element:  "tbody",
+			swapOOB:  "beforeend:#snippets tbody",
+			children: "\n<tr id=\"snippet-2\"><td>two</td></tr>",
+			template: true,
+		},
+		{
+			name:     "table cells wrapped in a row",
+			oob:      htmx.OOB(rawHTML(`<td>uno</td>`), htmx.SwapInnerHTML, "#snippet-1"),
+			element:  "tr",
+			swapOOB:  "innerHTML:#snippet-1",
+			children: "<td>uno</td>",
+			template: true,
+		},
+		{
+			name:     "table row swapped by id",
+			oob:      htmx.OOB(rawHTML(`<tr id="snippet-1"><td>uno</td></tr>`), htmx.SwapOuterHTML, ""),
+			element:  "tr",
+			swapOOB:  "true",
+			children: "<td>uno</td>",
+			template: true,
+		},
+		{
+			name:     "leading comment and whitespace",
+			oob:      htmx.OOB(rawHTML("<!-- counter -->\n  <span id=\"counter\">3</span>"), htmx.SwapOuterHTML, ""),
+			element:  "span",
+			swapOOB:  "true",
+			children: "3",
+		},
+		{
+			name:    "void element",
+			oob:     htmx.OOB(rawHTML(`<input id="search" value="snail">`), htmx.SwapOuterHTML, ""),
+			element: "input",
+			swapOOB: "true",
+		},
+		{
+			name:     "raw text holding tags",
+			oob:      htmx.OOB(rawHTML(`<div id="editor"><script>if (a<b) x = "</div>"</script></div>`), htmx.SwapOuterHTML, ""),
+			element:  "div",
+			swapOOB:  "true",
+			children: `<script>if (a<b) x = "</div>"</script>`,
+		},
+		{
+			name:     "attribute holding a tag",
+			oob:      htmx.OOB(rawHTML(`<span title="<b>" id='counter'>3</span>`), htmx.SwapOuterHTML, ""),
+			element:  "span",
+			swapOOB:  "true",
+			children: "3",
+		},
+	}
+
+	page := MustParseFragment(t, oobPage)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frag := RenderFragment(t, htmx.WithOOB(rawHTML(`<form id="snippet-form"></form>`), tt.oob))
+			frag.AssertOOBTargets(t, page)
+
+			elements := oobElements(frag)
+			if len(elements) != 1 {
+				t.Fatalf("found %d out of band elements, want 1:\n%s", len(elements), frag.HTML())
+			}
+			oob := elements[0]
+			if value, _ := oob.Attr("hx-swap-oob"); oob.Data != tt.element || value != tt.swapOOB {
+				t.Errorf("out of band element is <%s hx-swap-oob=%q>, want <%s hx-swap-oob=%q>", oob.Data, value, tt.element, tt.swapOOB)
+			}
+
+			var children strings.Builder
+			for _, child := range oob.Children {
+				child.render(&children)
+			}
+			if got := children.String(); got != tt.children {
+				t.Errorf("content = %q, want %q", got, tt.children)
+			}
+
+			// htmx only finds out of band elements at the root of the
+			// response, or of the content of its templates.
+			root := oob.Parent
+			if tt.template {
+				if root == frag || len(frag.QuerySelectorAll("template")) != 1 || frag.QuerySelector("template").Content != root {
+					t.Errorf("out of band element is not at the root of a template:\n%s", frag.HTML())
+				}
+			} else if root != frag {
+				t.Errorf("out of band element is not at the root of the response:\n%s", frag.HTML())
+			}
+		})
+	}
+}
+
+// recordingT records the failures reported by the assertions.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Error(args ...any) {
+	t.errors = append(t.errors, fmt.Sprint(args...))
+}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertOOBTargetsFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		oob  htmx.OOBComponent
+		err  string
+	}{
+		{
+			name: "missing id target",
+			oob:  htmx.OOB(rawHTML(`<span id="count">3</span>`), htmx.SwapOuterHTML, ""),
+			err:  `out of band target "#count" does not exist`,
+		},
+		{
+			name: "missing selector target",
+			oob:  htmx.OOB(rawHTML(`<tr><td>2</td></tr>`), htmx.SwapBeforeEnd, "#snippets tfoot"),
+			err:  `out of band target "#snippets tfoot" does not exist`,
+		},
+	}
+
+	page := MustParseFragment(t, oobPage)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := &recordingT{TB: t}
+			RenderFragment(t, htmx.WithOOB(nil, tt.oob)).AssertOOBTargets(rt, page)
+			if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], tt.err) {
+				t.Errorf("AssertOOBTargets() errors = %q, want %q", rt.errors, tt.err)
+			}
+		})
+	}
+
+	rt := &recordingT{TB: t}
+	MustParseFragment(t, `<p hx-swap-oob="true">x</p>`).AssertOOBTargets(rt, page)
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "has no id") {
+		t.Errorf("AssertOOBTargets() errors = %q, want a missing id", rt.errors)
+	}
+
+	rt = &recordingT{TB: t}
+	MustParseFragment(t, `<template hx-swap-oob="beforeend:#list"><li>x</li></template>`).AssertOOBTargets(rt, page)
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "out of band template swaps no content") {
+		t.Errorf("AssertOOBTargets() errors = %q, want a template", rt.errors)
+	}
+}
+
+func TestNodeAssertionFailures(t *testing.T) {
+	frag := MustParseFragment(t, `<ul id="list"><li class="a">one</li><li class="b">two</li></ul>`)
+	tests := []struct {
+		name   string
+		assert func(t testing.TB)
+		err    string
+	}{
+		{
+			name:   "exists",
+			assert: func(t testing.TB) { frag.AssertExists(t, "#list > li.c") },
+			err:    `selector "#list > li.c" matches nothing, although "#list" matches 1 element`,
+		},
+		{
+			name:   "missing",
+			assert: func(t testing.TB) { frag.AssertMissing(t, "li") },
+			err:    `selector "li" matches 2 elements, want none`,
+		},
+		{
+			name:   "count",
+			assert: func(t testing.TB) { frag.AssertCount(t, "li.a", 2) },
+			err:    `selector "li.a" matches 1 element, want 2`,
+		},
+		{
+			name:   "text",
+			assert: func(t testing.TB) { frag.AssertText(t, "li", "three") },
+			err:    `no element matching "li" has text "three", found:` + "\n\t\"one\"\n\t\"two\"",
+		},
+		{
+			name:   "attr",
+			assert: func(t testing.TB) { frag.AssertAttr(t, "li", "class", "c") },
+			err:    `no element matching "li" has attribute class="c"`,
+		},
+		{
+			name:   "invalid selector",
+			assert: func(t testing.TB) { frag.AssertExists(t, "li:hover") },
+			err:    "hover",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := &recordingT{TB: t}
+			tt.assert(rt)
+			if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], tt.err) {
+				t.Errorf("errors = %q, want %q", rt.errors, tt.err)
+			}
+		})
+	}
+
+	rt := &recordingT{TB: t}
+	frag.AssertText(rt, "li.b", "  two ")
+	frag.AssertAttr(rt, "#list li", "class", "a")
+	frag.AssertCount(rt, "li", 2)
+	frag.AssertMissing(rt, "li li")
+	if len(rt.errors) > 0 {
+		t.Errorf("passing assertions reported %q", rt.errors)
+	}
+}
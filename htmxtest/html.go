@@ -0,0 +1,458 @@
+package htmxtest
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// NodeType is the type of a parsed html node.
+type NodeType int
+
+const (
+	DocumentNode NodeType = iota
+	ElementNode
+	TextNode
+	CommentNode
+	DoctypeNode
+)
+
+// Attribute is an attribute of an element.
+type Attribute struct {
+	Name, Value string
+}
+
+// Node is a node of a parsed html document or fragment.
+type Node struct {
+	Type NodeType
+	// The lower case tag name of elements, the unescaped content of text
+	// nodes, and the content of comments and doctypes.
+	Data string
+	// The attributes of elements, with lower case names and unescaped values.
+	Attrs    []Attribute
+	Parent   *Node
+	Children []*Node
+	// The content of template elements, parsed into a separate document as
+	// browsers do: the content is neither among the children of the template,
+	// nor matched by the selector queries of the enclosing document.
+	Content *Node
+}
+
+// Attr returns the value of the attribute of the element, and whether the
+// attribute is present.
+func (n *Node) Attr(name string) (string, bool) {
+	for _, attr := range n.Attrs {
+		if attr.Name == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// ID returns the id attribute of the element.
+func (n *Node) ID() string {
+	id, _ := n.Attr("id")
+	return id
+}
+
+// HasClass returns true if the class attribute of the element lists the class.
+func (n *Node) HasClass(class string) bool {
+	classes, _ := n.Attr("class")
+	for _, c := range strings.Fields(classes) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Text returns the text content of the node and its descendants, with runs of
+// whitespace collapsed into single spaces and leading and trailing whitespace
+// removed.
+func (n *Node) Text() string {
+	var b strings.Builder
+	n.walk(func(n *Node) {
+		if n.Type == TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+	})
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Elements returns the element children of the node.
+func (n *Node) Elements() []*Node {
+	var elements []*Node
+	for _, child := range n.Children {
+		if child.Type == ElementNode {
+			elements = append(elements, child)
+		}
+	}
+	return elements
+}
+
+// walk calls fn for the node and every descendant, in document order.
+func (n *Node) walk(fn func(*Node)) {
+	fn(n)
+	for _, child := range n.Children {
+		child.walk(fn)
+	}
+}
+
+// HTML renders the node and its descendants.
+func (n *Node) HTML() string {
+	var b strings.Builder
+	n.render(&b)
+	return b.String()
+}
+
+func (n *Node) render(b *strings.Builder) {
+	switch n.Type {
+	case TextNode:
+		if n.Parent != nil && rawTextElements[n.Parent.Data] {
+			b.WriteString(n.Data)
+		} else {
+			b.WriteString(html.EscapeString(n.Data))
+		}
+	case CommentNode:
+		b.WriteString("<!--" + n.Data + "-->")
+	case DoctypeNode:
+		b.WriteString("<!" + n.Data + ">")
+	case ElementNode:
+		b.WriteString("<" + n.Data)
+		for _, attr := range n.Attrs {
+			b.WriteString(" " + attr.Name + `="` + html.EscapeString(attr.Value) + `"`)
+		}
+		b.WriteByte('>')
+		if voidElements[n.Data] {
+			return
+		}
+		children := n.Children
+		if n.Content != nil {
+			children = n.Content.Children
+		}
+		for _, child := range children {
+			child.render(b)
+		}
+		b.WriteString("</" + n.Data + ">")
+	default:
+		for _, child := range n.Children {
+			child.render(b)
+		}
+	}
+}
+
+var (
+	voidElements = setOf("area", "base", "br", "col", "embed", "hr", "img", "input",
+		"link", "meta", "source", "track", "wbr")
+
+	// elements whose content is not parsed as html.
+	rawTextElements = setOf("script", "style", "textarea", "title")
+
+	// elements whose end tag may be omitted.
+	optionalEndElements = setOf("p", "li", "dt", "dd", "tr", "td", "th", "thead",
+		"tbody", "tfoot", "option", "optgroup", "colgroup", "caption", "html", "head", "body")
+
+	// elements closing an open paragraph.
+	paragraphClosers = setOf("address", "article", "aside", "blockquote", "details",
+		"div", "dl", "fieldset", "figcaption", "figure", "footer", "form", "h1", "h2",
+		"h3", "h4", "h5", "h6", "header", "hr", "main", "menu", "nav", "ol", "p", "pre",
+		"section", "table", "ul")
+
+	// elements implicitly closed by the start tag of an element, up to the
+	// boundary elements.
+	implicitClosers = map[string]struct{ closes, boundaries map[string]bool }{
+		"li":       {setOf("li"), setOf("ul", "ol", "menu")},
+		"dt":       {setOf("dt", "dd"), setOf("dl")},
+		"dd":       {setOf("dt", "dd"), setOf("dl")},
+		"tr":       {setOf("tr", "td", "th"), setOf("table", "thead", "tbody", "tfoot")},
+		"td":       {setOf("td", "th"), setOf("tr", "table")},
+		"th":       {setOf("td", "th"), setOf("tr", "table")},
+		"thead":    {setOf("thead", "tbody", "tfoot", "tr", "td", "th", "caption", "colgroup"), setOf("table")},
+		"tbody":    {setOf("thead", "tbody", "tfoot", "tr", "td", "th", "caption", "colgroup"), setOf("table")},
+		"tfoot":    {setOf("thead", "tbody", "tfoot", "tr", "td", "th", "caption", "colgroup"), setOf("table")},
+		"option":   {setOf("option"), setOf("select", "datalist", "optgroup")},
+		"optgroup": {setOf("option", "optgroup"), setOf("select")},
+	}
+)
+
+func setOf(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// ParseHTML parses an html document or fragment, such as the response of an
+// htmx handler. End tags that html allows to omit, such as those of <p>, <li>
+// or <td>, are implied. Since the parser is meant to catch broken templates,
+// an error is returned for unterminated tags and comments, end tags without a
+// matching start tag, and elements left open; the returned document holds the
+// content parsed so far.
+func ParseHTML(s string) (*Node, error) {
+	p := parser{src: s, doc: &Node{Type: DocumentNode}}
+	p.stack = []*Node{p.doc}
+	err := p.parse()
+	return p.doc, err
+}
+
+type parser struct {
+	src   string
+	pos   int
+	doc   *Node
+	stack []*Node
+}
+
+func (p *parser) errorf(pos int, format string, args ...any) error {
+	line := strings.Count(p.src[:pos], "\n") + 1
+	return fmt.Errorf("htmxtest: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// current returns the node receiving the parsed nodes: the innermost open
+// element, or its content if it is a template.
+func (p *parser) current() *Node {
+	if n := p.stack[len(p.stack)-1]; n.Content != nil {
+		return n.Content
+	}
+	return p.stack[len(p.stack)-1]
+}
+
+func (p *parser) append(n *Node) {
+	parent := p.current()
+	n.Parent = parent
+	parent.Children = append(parent.Children, n)
+}
+
+func (p *parser) text(s string) {
+	if s == "" {
+		return
+	}
+	parent := p.current()
+	if last := len(parent.Children) - 1; last >= 0 && parent.Children[last].Type == TextNode {
+		parent.Children[last].Data += s
+		return
+	}
+	p.append(&Node{Type: TextNode, Data: s})
+}
+
+func (p *parser) parse() error {
+	for p.pos < len(p.src) {
+		i := strings.IndexByte(p.src[p.pos:], '<')
+		if i < 0 {
+			p.text(html.UnescapeString(p.src[p.pos:]))
+			break
+		}
+		p.text(html.UnescapeString(p.src[p.pos : p.pos+i]))
+		p.pos += i
+
+		rest := p.src[p.pos:]
+		var err error
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			err = p.parseComment()
+		case strings.HasPrefix(rest, "<!"):
+			err = p.parseDoctype()
+		case strings.HasPrefix(rest, "</"):
+			err = p.parseEndTag()
+		case len(rest) > 1 && isLetter(rest[1]):
+			err = p.parseStartTag()
+		default:
+			p.text("<")
+			p.pos++
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for len(p.stack) > 1 {
+		n := p.stack[len(p.stack)-1]
+		if !optionalEndElements[n.Data] {
+			return p.errorf(len(p.src), "unclosed <%s>", n.Data)
+		}
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+	return nil
+}
+
+func (p *parser) parseComment() error {
+	end := strings.Index(p.src[p.pos+4:], "-->")
+	if end < 0 {
+		return p.errorf(p.pos, "unterminated comment")
+	}
+	p.append(&Node{Type: CommentNode, Data: p.src[p.pos+4 : p.pos+4+end]})
+	p.pos += 4 + end + 3
+	return nil
+}
+
+func (p *parser) parseDoctype() error {
+	end := strings.IndexByte(p.src[p.pos:], '>')
+	if end < 0 {
+		return p.errorf(p.pos, "unterminated doctype")
+	}
+	p.append(&Node{Type: DoctypeNode, Data: p.src[p.pos+2 : p.pos+end]})
+	p.pos += end + 1
+	return nil
+}
+
+func (p *parser) parseEndTag() error {
+	start := p.pos
+	end := strings.IndexByte(p.src[p.pos:], '>')
+	if end < 0 {
+		return p.errorf(start, "unterminated end tag")
+	}
+	name := strings.ToLower(strings.TrimSpace(p.src[p.pos+2 : p.pos+end]))
+	p.pos += end + 1
+
+	for i := len(p.stack) - 1; i > 0; i-- {
+		n := p.stack[i]
+		if n.Data == name {
+			p.stack = p.stack[:i]
+			return nil
+		} else if !optionalEndElements[n.Data] {
+			return p.errorf(start, "unexpected </%s>, <%s> is not closed", name, n.Data)
+		}
+	}
+	return p.errorf(start, "unexpected </%s>", name)
+}
+
+func (p *parser) parseStartTag() error {
+	start := p.pos
+	p.pos++
+	name := strings.ToLower(p.scan(func(c byte) bool { return !isSpace(c) && c != '/' && c != '>' }))
+	n := &Node{Type: ElementNode, Data: name}
+	if name == "template" {
+		n.Content = &Node{Type: DocumentNode}
+	}
+
+	selfClosing := false
+	for {
+		p.scan(isSpace)
+		if p.pos >= len(p.src) {
+			return p.errorf(start, "unterminated <%s> tag", name)
+		}
+		c := p.src[p.pos]
+		if c == '>' {
+			p.pos++
+			break
+		} else if c == '/' {
+			p.pos++
+			selfClosing = p.pos < len(p.src) && p.src[p.pos] == '>'
+			continue
+		}
+
+		attr := Attribute{Name: strings.ToLower(p.scan(func(c byte) bool {
+			return !isSpace(c) && c != '/' && c != '>' && c != '='
+		}))}
+		p.scan(isSpace)
+		if p.pos < len(p.src) && p.src[p.pos] == '=' {
+			p.pos++
+			p.scan(isSpace)
+			value, err := p.scanAttrValue(start, name)
+			if err != nil {
+				return err
+			}
+			attr.Value = html.UnescapeString(value)
+		}
+		if _, ok := n.Attr(attr.Name); !ok {
+			n.Attrs = append(n.Attrs, attr)
+		}
+	}
+
+	p.closeImplied(name)
+	p.append(n)
+	switch {
+	case voidElements[name] || selfClosing:
+		return nil
+	case rawTextElements[name]:
+		return p.parseRawText(n)
+	}
+	p.stack = append(p.stack, n)
+	return nil
+}
+
+func (p *parser) scanAttrValue(start int, name string) (string, error) {
+	if p.pos < len(p.src) && (p.src[p.pos] == '"' || p.src[p.pos] == '\'') {
+		quote := p.src[p.pos]
+		end := strings.IndexByte(p.src[p.pos+1:], quote)
+		if end < 0 {
+			return "", p.errorf(start, "unterminated attribute value in <%s> tag", name)
+		}
+		value := p.src[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return value, nil
+	}
+	return p.scan(func(c byte) bool { return !isSpace(c) && c != '>' }), nil
+}
+
+// parseRawText parses the content of the element up to its end tag, without
+// recognizing any tag within.
+func (p *parser) parseRawText(n *Node) error {
+	end := strings.Index(strings.ToLower(p.src[p.pos:]), "</"+n.Data)
+	if end < 0 {
+		return p.errorf(p.pos, "unclosed <%s>", n.Data)
+	}
+	text := p.src[p.pos : p.pos+end]
+	if n.Data == "textarea" || n.Data == "title" {
+		text = html.UnescapeString(text)
+	}
+	if text != "" {
+		n.Children = append(n.Children, &Node{Type: TextNode, Data: text, Parent: n})
+	}
+	p.pos += end
+	gt := strings.IndexByte(p.src[p.pos:], '>')
+	if gt < 0 {
+		return p.errorf(p.pos, "unterminated end tag")
+	}
+	p.pos += gt + 1
+	return nil
+}
+
+// closeImplied closes the open elements whose end tag is implied by the start
+// tag of the element.
+func (p *parser) closeImplied(name string) {
+	if paragraphClosers[name] {
+		for i := len(p.stack) - 1; i > 0; i-- {
+			if n := p.stack[i]; n.Data == "p" {
+				p.stack = p.stack[:i]
+				break
+			} else if !optionalEndElements[n.Data] {
+				break
+			}
+		}
+	}
+
+	rule, ok := implicitClosers[name]
+	if !ok {
+		return
+	}
+	cut := len(p.stack)
+	for i := len(p.stack) - 1; i > 0; i-- {
+		n := p.stack[i]
+		if rule.boundaries[n.Data] {
+			break
+		} else if rule.closes[n.Data] {
+			cut = i
+		} else if !optionalEndElements[n.Data] {
+			break
+		}
+	}
+	p.stack = p.stack[:cut]
+}
+
+func (p *parser) scan(accept func(byte) bool) string {
+	start := p.pos
+	for p.pos < len(p.src) && accept(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
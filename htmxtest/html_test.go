@@ -0,0 +1,163 @@
+package htmxtest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		html string
+	}{
+		{
+			name: "elements and attributes",
+			src:  `<DIV Id="a" class='b c' hidden data-x=1>text &amp; more</DIV>`,
+			html: `<div id="a" class="b c" hidden="" data-x="1">text &amp; more</div>`,
+		},
+		{
+			name: "duplicate attributes keep the first",
+			src:  `<a href="/one" href="/two">x</a>`,
+			html: `<a href="/one">x</a>`,
+		},
+		{
+			name: "void and self closing elements",
+			src:  `<p>a<br>b<img src="x.png"/><input disabled></p><span/>`,
+			html: `<p>a<br>b<img src="x.png"><input disabled=""></p><span></span>`,
+		},
+		{
+			name: "comments and doctype",
+			src:  `<!DOCTYPE html><!-- a <b> comment --><p>x</p>`,
+			html: `<!DOCTYPE html><!-- a <b> comment --><p>x</p>`,
+		},
+		{
+			name: "stray less than",
+			src:  `<p>1 < 2</p>`,
+			html: `<p>1 &lt; 2</p>`,
+		},
+		{
+			name: "paragraphs",
+			src:  `<p>one<p>two<div>three</div>`,
+			html: `<p>one</p><p>two</p><div>three</div>`,
+		},
+		{
+			name: "list items",
+			src:  `<ul><li>one<li>two<ul><li>nested</ul><li>three</ul>`,
+			html: `<ul><li>one</li><li>two<ul><li>nested</li></ul></li><li>three</li></ul>`,
+		},
+		{
+			name: "definition lists",
+			src:  `<dl><dt>term<dd>one<dd>two<dt>other</dl>`,
+			html: `<dl><dt>term</dt><dd>one</dd><dd>two</dd><dt>other</dt></dl>`,
+		},
+		{
+			name: "table rows and cells",
+			src:  `<table><thead><tr><th>a<th>b<tbody><tr><td>1<td>2<tr><td>3</table>`,
+			html: `<table><thead><tr><th>a</th><th>b</th></tr></thead><tbody><tr><td>1</td><td>2</td></tr><tr><td>3</td></tr></tbody></table>`,
+		},
+		{
+			name: "options",
+			src:  `<select><optgroup label="a"><option>1<option>2<optgroup label="b"><option>3</select>`,
+			html: `<select><optgroup label="a"><option>1</option><option>2</option></optgroup><optgroup label="b"><option>3</option></optgroup></select>`,
+		},
+		{
+			name: "optional end tags left open",
+			src:  `<p>one<p>two`,
+			html: `<p>one</p><p>two</p>`,
+		},
+		{
+			name: "raw text elements",
+			src:  `<script>if (a < b && c) { x = "</div>" }</script><style>p > a {}</style><textarea>&lt;b&gt;</textarea><title>A &amp; B</title>`,
+			html: `<script>if (a < b && c) { x = "</div>" }</script><style>p > a {}</style><textarea><b></textarea><title>A & B</title>`,
+		},
+		{
+			name: "raw text end tag case",
+			src:  `<SCRIPT>x<y</Script>`,
+			html: `<script>x<y</script>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseHTML(tt.src)
+			if err != nil {
+				t.Fatalf("ParseHTML() error = %v", err)
+			}
+			if got := doc.HTML(); got != tt.html {
+				t.Errorf("ParseHTML().HTML() =\n\t%s\nwant\n\t%s", got, tt.html)
+			}
+		})
+	}
+}
+
+func TestParseHTMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{name: "unclosed element", src: "<div><span>x</span>", err: "line 1: unclosed <div>"},
+		{name: "unclosed list", src: "<ul><li>one<li>two", err: "unclosed <ul>"},
+		{name: "unclosed raw text", src: "<script>x", err: "unclosed <script>"},
+		{name: "unterminated tag", src: `<div class="a"`, err: "unterminated <div> tag"},
+		{name: "unterminated attribute value", src: `<div class="a>x</div>`, err: "unterminated attribute value in <div> tag"},
+		{name: "unterminated comment", src: "<p>x</p>\n<!-- x", err: "line 2: unterminated comment"},
+		{name: "unterminated doctype", src: "<!DOCTYPE html", err: "unterminated doctype"},
+		{name: "unterminated end tag", src: "<div>x</div", err: "unterminated end tag"},
+		{name: "unterminated raw text end tag", src: "<style>x</style", err: "unterminated end tag"},
+		{name: "mismatched end tag", src: "<div><span>x</div></span>", err: "unexpected </div>, <span> is not closed"},
+		{name: "end tag without start tag", src: "<p>x</p>\n\n</div>", err: "line 3: unexpected </div>"},
+		{name: "end tag of a closed optional element", src: "<ul><li>x</ul></li>", err: "unexpected </li>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseHTML(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseHTML() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNodeText(t *testing.T) {
+	doc, err := ParseHTML("<div id=a class=\"x  y\">\n  Hello,\n\t<b>brave</b>   new <!-- not --> world\n</div>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := doc.Elements()[0]
+	if got := n.Text(); got != "Hello, brave new world" {
+		t.Errorf("Text() = %q, want %q", got, "Hello, brave new world")
+	}
+	if n.ID() != "a" || !n.HasClass("y") || n.HasClass("x  y") {
+		t.Errorf("ID() = %q, classes %q, want id a with classes x and y", n.ID(), n.Attrs[1].Value)
+	}
+}
+
+func TestParseHTMLTemplate(t *testing.T) {
+	doc, err := ParseHTML(`<div id="d"><template id="t"><tr id="r"><td>x<td>y</template><p id="p">z</div>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := doc.QuerySelector("#t")
+	if tmpl == nil || tmpl.Content == nil {
+		t.Fatalf("template has no content: %s", doc.HTML())
+	}
+	if len(tmpl.Children) != 0 || tmpl.Text() != "" {
+		t.Errorf("template has children %v, want its content kept apart", tmpl.Children)
+	}
+	if got := doc.QuerySelector("#r"); got != nil {
+		t.Errorf("QuerySelector() matched %s within the template content", got.HTML())
+	}
+	if got := ids(tmpl.Content.QuerySelectorAll("tr, td")); got != "r  " {
+		t.Errorf("content QuerySelectorAll() = %q, want the row and cells", got)
+	}
+	if got := doc.QuerySelector("#p"); got == nil || got.Parent != doc.QuerySelector("#d") {
+		t.Error("element following the template is not a sibling of the template")
+	}
+	want := `<div id="d"><template id="t"><tr id="r"><td>x</td><td>y</td></tr></template><p id="p">z</p></div>`
+	if got := doc.HTML(); got != want {
+		t.Errorf("HTML() =\n\t%s\nwant\n\t%s", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+package htmxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/nisimpson/htmx"
+)
+
+// Recorder records the response of a handler, as httptest.ResponseRecorder
+// does, and parses the htmx response headers into typed values. The accessors
+// and assertions read the headers as sent with the response, so that headers
+// set after the body was written, and lost by the client, are reported
+// missing.
+type Recorder struct {
+	*httptest.ResponseRecorder
+}
+
+// sentHeader returns the response headers as sent, when the header was
+// written.
+func (r *Recorder) sentHeader() http.Header {
+	return r.Result().Header
+}
+
+// NewRecorder returns an initialized recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+// Serve records the response of the handler to the request.
+func Serve(handler http.Handler, r *http.Request) *Recorder {
+	rec := NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+// ServeHTMX records the response of the htmx handler, wrapped with HTMX() and
+// the options, to the request.
+func ServeHTMX(handler htmx.Handler, r *http.Request, opts ...htmx.Option) *Recorder {
+	return Serve(htmx.HTMX(handler, opts...), r)
+}
+
+// Event is a client side event triggered by a response header.
+type Event struct {
+	// The name of the event.
+	Name string
+	// The JSON encoded detail of the event, or nil if the header only lists
+	// event names.
+	Detail json.RawMessage
+}
+
+// DecodeDetail decodes the detail of the event into v.
+func (e Event) DecodeDetail(v any) error {
+	if e.Detail == nil {
+		return fmt.Errorf("htmxtest: event %q has no detail", e.Name)
+	}
+	return json.Unmarshal(e.Detail, v)
+}
+
+// Triggers returns the events triggered during the phase, in the order of the
+// header, or nil if the header is absent. An error is returned if the header is
+// malformed.
+func (r *Recorder) Triggers(phase htmx.TriggerPhase) ([]Event, error) {
+	return ParseTriggers(r.sentHeader().Get(triggerHeader(phase)))
+}
+
+func triggerHeader(phase htmx.TriggerPhase) string {
+	switch phase {
+	case htmx.PhaseAfterSwap:
+		return htmx.HeaderHXTriggerAfterSwap
+	case htmx.PhaseAfterSettle:
+		return htmx.HeaderHXTriggerAfterSettle
+	}
+	return htmx.HeaderHXTrigger
+}
+
+// ParseTriggers parses the value of the "HX-Trigger", "HX-Trigger-After-Swap"
+// and "HX-Trigger-After-Settle" headers, either a comma separated list of
+// event names or a JSON object keyed by event name. Events are returned in the
+// order of the header.
+func ParseTriggers(value string) ([]Event, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(value, "{") {
+		var events []Event
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				events = append(events, Event{Name: name})
+			}
+		}
+		return events, nil
+	}
+
+	// decode token by token to preserve the order of the events.
+	dec := json.NewDecoder(strings.NewReader(value))
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("htmxtest: malformed trigger header %q: %w", value, err)
+	}
+	var events []Event
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("htmxtest: malformed trigger header %q: %w", value, err)
+		}
+		var detail json.RawMessage
+		if err := dec.Decode(&detail); err != nil {
+			return nil, fmt.Errorf("htmxtest: malformed trigger header %q: %w", value, err)
+		}
+		events = append(events, Event{Name: token.(string), Detail: detail})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("htmxtest: malformed trigger header %q: %w", value, err)
+	}
+	return events, nil
+}
+
+// Location returns the parsed "HX-Location" header, and whether the header is
+// present. An error is returned if the header is malformed.
+func (r *Recorder) Location() (htmx.Location, bool, error) {
+	value := r.sentHeader().Get(htmx.HeaderHXLocation)
+	if value == "" {
+		return htmx.Location{}, false, nil
+	}
+	location, err := htmx.ParseLocation(value)
+	return location, true, err
+}
+
+// Reswap returns the parsed "HX-Reswap" header, and whether the header is
+// present. An error is returned if the header is malformed.
+func (r *Recorder) Reswap() (htmx.Swap, bool, error) {
+	value := r.sentHeader().Get(htmx.HeaderHXReswap)
+	if value == "" {
+		return htmx.Swap{}, false, nil
+	}
+	swap, err := htmx.ParseSwap(value)
+	return swap, true, err
+}
+
+// Retarget returns the "HX-Retarget" header.
+func (r *Recorder) Retarget() string {
+	return r.sentHeader().Get(htmx.HeaderHXRetarget)
+}
+
+// Reselect returns the "HX-Reselect" header.
+func (r *Recorder) Reselect() string {
+	return r.sentHeader().Get(htmx.HeaderHXReselect)
+}
+
+// Redirect returns the "HX-Redirect" header.
+func (r *Recorder) Redirect() string {
+	return r.sentHeader().Get(htmx.HeaderHXRedirect)
+}
+
+// PushURL returns the "HX-Push-Url" header.
+func (r *Recorder) PushURL() string {
+	return r.sentHeader().Get(htmx.HeaderHXPushURL)
+}
+
+// ReplaceURL returns the "HX-Replace-Url" header.
+func (r *Recorder) ReplaceURL() string {
+	return r.sentHeader().Get(htmx.HeaderHXReplaceURL)
+}
+
+// Refresh returns true if the "HX-Refresh" header is set to "true".
+func (r *Recorder) Refresh() bool {
+	return r.sentHeader().Get(htmx.HeaderHXRefresh) == "true"
+}
+
+// indentJSON returns the JSON value indented for display, or the value as is
+// if it is not valid JSON.
+func indentJSON(data []byte) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return buf.String()
+}
@@ -0,0 +1,272 @@
+package htmxtest
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/htmx"
+)
+
+func TestParseTriggers(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []Event
+		wantErr bool
+	}{
+		{name: "absent", value: ""},
+		{name: "single name", value: "saved", want: []Event{{Name: "saved"}}},
+		{
+			name:  "names",
+			value: "saved, closed ,,reset",
+			want:  []Event{{Name: "saved"}, {Name: "closed"}, {Name: "reset"}},
+		},
+		{
+			name:  "json keeps the header order",
+			value: `{"zeta":1,"alpha":{"id":2},"mid":"text"}`,
+			want: []Event{
+				{Name: "zeta", Detail: []byte(`1`)},
+				{Name: "alpha", Detail: []byte(`{"id":2}`)},
+				{Name: "mid", Detail: []byte(`"text"`)},
+			},
+		},
+		{
+			name:  "named and detailed events",
+			value: `{"saved":{},"count":3}`,
+			want:  []Event{{Name: "saved", Detail: []byte(`{}`)}, {Name: "count", Detail: []byte(`3`)}},
+		},
+		{name: "empty object", value: `{}`},
+		{name: "unterminated object", value: `{"saved":1`, wantErr: true},
+		{name: "missing detail", value: `{"saved"}`, wantErr: true},
+		{name: "trailing comma", value: `{"saved":1,}`, wantErr: true},
+		{name: "invalid detail", value: `{"saved":nope}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTriggers(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTriggers(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTriggers(%q) error = %v", tt.value, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseTriggers(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+			for i := range got {
+				if got[i].Name != tt.want[i].Name || string(got[i].Detail) != string(tt.want[i].Detail) {
+					t.Errorf("event %d = %s %s, want %s %s", i, got[i].Name, got[i].Detail, tt.want[i].Name, tt.want[i].Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestRecorderTriggers(t *testing.T) {
+	rec := NewRecorder()
+	w := htmx.NewResponseWriter(rec)
+	w.SetTriggerHeader(htmx.TriggerEvents("first"))
+	w.SetTriggerHeader(htmx.TriggerEventsWithContext(map[string]any{"second": map[string]any{"id": 7}}))
+	w.SetTriggerAfterSwapHeader(htmx.TriggerEvents("swapped"))
+	w.WriteHeader(200)
+
+	events, err := rec.Triggers(htmx.PhaseReceived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Name != "first" || events[1].Name != "second" {
+		t.Fatalf("Triggers() = %v, want first and second", events)
+	}
+	var detail struct{ ID int }
+	if err := events[1].DecodeDetail(&detail); err != nil || detail.ID != 7 {
+		t.Errorf("DecodeDetail() = %+v, %v, want id 7", detail, err)
+	}
+
+	swapped, err := rec.Triggers(htmx.PhaseAfterSwap)
+	if err != nil || len(swapped) != 1 {
+		t.Fatalf("Triggers() = %v, %v, want swapped", swapped, err)
+	}
+	if err := swapped[0].DecodeDetail(&detail); err == nil {
+		t.Error("DecodeDetail() of a named event succeeded, want an error")
+	}
+
+	rec.AssertTriggers(t, htmx.PhaseAfterSwap, "swapped")
+	rec.AssertTriggers(t, htmx.PhaseAfterSettle)
+}
+
+func TestRecorderLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		location htmx.Location
+	}{
+		{name: "path only", location: htmx.Location{Path: "/snippets"}},
+		{
+			name: "options",
+			location: htmx.Location{
+				Path:    "/snippets?page=2",
+				Source:  "#list",
+				Event:   "click",
+				Handler: "handle",
+				Target:  "#main",
+				Swap:    htmx.NewSwap(htmx.SwapInnerHTML).SwapDelay(time.Second).ShowTarget("#main", htmx.ScrollTop),
+				Values:  map[string]any{"created": "3"},
+				Headers: map[string]string{"X-Mode": "compact"},
+				Select:  "#content",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := NewRecorder()
+			if err := htmx.NewResponseWriter(rec).SetLocationOptionsHeader(tt.location); err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok, err := rec.Location()
+			if !ok || err != nil {
+				t.Fatalf("Location() = %v, %v, want the header", ok, err)
+			}
+			rec.AssertLocation(t, tt.location)
+			if got.Swap.String() != tt.location.Swap.String() {
+				t.Errorf("Location().Swap = %q, want %q", got.Swap, tt.location.Swap)
+			}
+			got.Swap, tt.location.Swap = htmx.Swap{}, htmx.Swap{}
+			if !reflect.DeepEqual(got, tt.location) {
+				t.Errorf("Location() = %+v, want %+v", got, tt.location)
+			}
+		})
+	}
+
+	rec := NewRecorder()
+	if err := htmx.NewResponseWriter(rec).SetLocationHeader(url.URL{Path: "/home"}); err != nil {
+		t.Fatal(err)
+	}
+	rec.AssertLocation(t, htmx.Location{Path: "/home"})
+
+	if _, ok, err := NewRecorder().Location(); ok || err != nil {
+		t.Errorf("Location() of an empty response = %v, %v, want no header", ok, err)
+	}
+}
+
+func TestRecorderReswap(t *testing.T) {
+	tests := []htmx.Swap{
+		htmx.NewSwap(htmx.SwapOuterHTML),
+		htmx.NewSwap(htmx.SwapInnerHTML).SwapDelay(time.Second).SettleDelay(200 * time.Millisecond),
+		htmx.NewSwap(htmx.SwapBeforeEnd).Scroll(htmx.ScrollBottom).FocusScroll(true),
+		htmx.NewSwap(htmx.SwapAfterBegin).ShowTarget("#list", htmx.ScrollTop).Transition(true),
+		htmx.NewSwap(htmx.SwapNone).ShowNone().IgnoreTitle(true),
+	}
+
+	for _, swap := range tests {
+		t.Run(swap.String(), func(t *testing.T) {
+			rec := NewRecorder()
+			if err := htmx.NewResponseWriter(rec).SetReswapHeader(swap); err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok, err := rec.Reswap()
+			if !ok || err != nil {
+				t.Fatalf("Reswap() = %v, %v, want the header", ok, err)
+			}
+			if got.String() != swap.String() {
+				t.Errorf("Reswap() = %q, want %q", got, swap)
+			}
+			rec.AssertReswap(t, swap)
+		})
+	}
+
+	rec := NewRecorder()
+	rec.Header().Set(htmx.HeaderHXReswap, "sideways")
+	if _, ok, err := rec.Reswap(); !ok || err == nil || !strings.Contains(err.Error(), "sideways") {
+		t.Errorf("Reswap() of an invalid header = %v, %v, want an error", ok, err)
+	}
+}
+
+func TestRecorderHeadersSetAfterBody(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(w *htmx.ResponseWriter)
+		got  func(rec *Recorder) bool
+	}{
+		{
+			name: "retarget",
+			set:  func(w *htmx.ResponseWriter) { w.SetRetargetHeader("#late") },
+			got:  func(rec *Recorder) bool { return rec.Retarget() != "" },
+		},
+		{
+			name: "reselect",
+			set:  func(w *htmx.ResponseWriter) { w.SetReselectHeader("#late") },
+			got:  func(rec *Recorder) bool { return rec.Reselect() != "" },
+		},
+		{
+			name: "reswap",
+			set:  func(w *htmx.ResponseWriter) { w.SetReswapHeader(htmx.NewSwap(htmx.SwapNone)) },
+			got: func(rec *Recorder) bool {
+				_, ok, _ := rec.Reswap()
+				return ok
+			},
+		},
+		{
+			name: "location",
+			set:  func(w *htmx.ResponseWriter) { w.SetLocationHeader(url.URL{Path: "/late"}) },
+			got: func(rec *Recorder) bool {
+				_, ok, _ := rec.Location()
+				return ok
+			},
+		},
+		{
+			name: "redirect",
+			set:  func(w *htmx.ResponseWriter) { w.SetRedirectHeader(url.URL{Path: "/late"}) },
+			got:  func(rec *Recorder) bool { return rec.Redirect() != "" },
+		},
+		{
+			name: "push url",
+			set:  func(w *htmx.ResponseWriter) { w.SetPushHeader(url.URL{Path: "/late"}) },
+			got:  func(rec *Recorder) bool { return rec.PushURL() != "" },
+		},
+		{
+			name: "replace url",
+			set:  func(w *htmx.ResponseWriter) { w.SetReplaceURLHeader(url.URL{Path: "/late"}) },
+			got:  func(rec *Recorder) bool { return rec.ReplaceURL() != "" },
+		},
+		{
+			name: "refresh",
+			set:  func(w *htmx.ResponseWriter) { w.SetRefreshHeader() },
+			got:  func(rec *Recorder) bool { return rec.Refresh() },
+		},
+		{
+			name: "trigger",
+			set:  func(w *htmx.ResponseWriter) { w.SetTriggerHeader(htmx.TriggerEvents("late")) },
+			got: func(rec *Recorder) bool {
+				events, _ := rec.Triggers(htmx.PhaseReceived)
+				return len(events) > 0
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ServeHTMX(htmx.HandlerFunc(func(w *htmx.ResponseWriter, r *htmx.Request) {
+				w.Write([]byte("<p>body</p>"))
+				tt.set(w)
+			}), NewRequest("GET", "/", nil))
+
+			if tt.got(rec) {
+				t.Errorf("header set after the body is reported, sent headers are %v", rec.Result().Header)
+			}
+			for key := range rec.Header() {
+				if strings.HasPrefix(key, "Hx-") {
+					rec.AssertHeader(t, key, "")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Package htmxtest provides utilities for testing htmx handlers: requests
+// carrying the headers sent by the htmx client, a recorder parsing the htmx
+// response headers into typed values, assertions reporting readable
+// differences, and an html parser running CSS selector queries against the
+// rendered fragments. For example:
+//
+//	func TestCreateSnippet(t *testing.T) {
+//		req := htmxtest.NewFormRequest("/snippets", url.Values{"title": {"O snail"}},
+//			htmxtest.WithTarget("snippet-form"),
+//			htmxtest.WithTriggerName("create"),
+//		)
+//		rec := htmxtest.Serve(handler, req)
+//
+//		rec.AssertStatus(t, http.StatusCreated)
+//		rec.AssertTrigger(t, htmx.PhaseReceived, "snippet-created", map[string]any{"id": 3})
+//		rec.AssertReswap(t, htmx.NewSwap(htmx.SwapOuterHTML))
+//
+//		frag := rec.Fragment(t)
+//		frag.AssertText(t, "#snippet-3 td", "O snail")
+//		frag.AssertOOBTargets(t, htmxtest.RenderFragment(t, homePage))
+//	}
+package htmxtest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/nisimpson/htmx"
+)
+
+// RequestOption sets htmx request headers of a test request.
+type RequestOption func(*http.Request)
+
+// NewRequest returns an incoming server request, as httptest.NewRequest()
+// does, made by the htmx client: the "HX-Request" header is set to "true",
+// along with the headers set by the options.
+func NewRequest(method, target string, body io.Reader, opts ...RequestOption) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	r.Header.Set(htmx.HeaderHXRequest, "true")
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// NewPageRequest returns an incoming server request made by the browser
+// rather than the htmx client, such as a full page load. The options may still
+// set htmx request headers.
+func NewPageRequest(method, target string, body io.Reader, opts ...RequestOption) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// NewFormRequest returns a POST request made by the htmx client, with the
+// values url encoded into the body, as htmx submits forms.
+func NewFormRequest(target string, values url.Values, opts ...RequestOption) *http.Request {
+	r := NewRequest(http.MethodPost, target, strings.NewReader(values.Encode()), opts...)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+// WithTriggerID sets the "HX-Trigger" header, the id of the element that
+// triggered the request.
+func WithTriggerID(id string) RequestOption {
+	return WithHeader(htmx.HeaderHXTrigger, id)
+}
+
+// WithTriggerName sets the "HX-Trigger-Name" header, the name of the element
+// that triggered the request.
+func WithTriggerName(name string) RequestOption {
+	return WithHeader(htmx.HeaderHXTriggerName, name)
+}
+
+// WithTarget sets the "HX-Target" header, the id of the target element.
+func WithTarget(id string) RequestOption {
+	return WithHeader(htmx.HeaderHXTarget, id)
+}
+
+// WithBoosted sets the "HX-Boosted" header, as sent by elements using
+// "hx-boost".
+func WithBoosted() RequestOption {
+	return WithHeader(htmx.HeaderHXBoosted, "true")
+}
+
+// WithHistoryRestore sets the "HX-History-Restore-Request" header, as sent
+// when the page is missing from the local history cache.
+func WithHistoryRestore() RequestOption {
+	return WithHeader(htmx.HeaderHXHistoryRestoreRequest, "true")
+}
+
+// WithCurrentURL sets the "HX-Current-Url" header, the current url of the
+// browser.
+func WithCurrentURL(currentURL string) RequestOption {
+	return WithHeader(htmx.HeaderHXCurrentURL, currentURL)
+}
+
+// WithPrompt sets the "HX-Prompt" header, the response of the user to an
+// "hx-prompt".
+func WithPrompt(response string) RequestOption {
+	return WithHeader(htmx.HeaderHXPrompt, response)
+}
+
+// WithHeader sets a request header. As with the htmx client, values that
+// cannot be sent as is, such as non-ASCII text, are URI encoded and flagged by
+// the companion "*-URI-AutoEncoded" header.
+func WithHeader(key, value string) RequestOption {
+	return func(r *http.Request) {
+		if !needsEncoding(value) {
+			r.Header.Set(key, value)
+			r.Header.Del(key + "-URI-AutoEncoded")
+			return
+		}
+		r.Header.Set(key, encodeURIComponent(value))
+		r.Header.Set(key+"-URI-AutoEncoded", "true")
+	}
+}
+
+func needsEncoding(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c < ' ' || c > '~' {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeURIComponent encodes the value as the javascript function of the same
+// name does.
+func encodeURIComponent(value string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.!~*'()", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package htmxtest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nisimpson/htmx"
+)
+
+func TestWithHeaderEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		header  string
+		encoded bool
+	}{
+		{name: "ascii", value: "Delete 50% of items?", header: "Delete 50% of items?"},
+		{name: "accents", value: "Crème brûlée", header: "Cr%C3%A8me%20br%C3%BBl%C3%A9e", encoded: true},
+		{name: "cjk", value: "日本", header: "%E6%97%A5%E6%9C%AC", encoded: true},
+		{name: "control characters", value: "line\nbreak", header: "line%0Abreak", encoded: true},
+		{name: "reserved characters", value: "é/?#&+%", header: "%C3%A9%2F%3F%23%26%2B%25", encoded: true},
+		{name: "unreserved characters", value: "ü-_.!~*'()", header: "%C3%BC-_.!~*'()", encoded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRequest(http.MethodGet, "/", nil,
+				WithPrompt(tt.value),
+				WithTarget(tt.value),
+				WithTriggerID(tt.value),
+				WithTriggerName(tt.value),
+			)
+
+			if got := r.Header.Get(htmx.HeaderHXPrompt); got != tt.header {
+				t.Errorf("%s = %q, want %q", htmx.HeaderHXPrompt, got, tt.header)
+			}
+			if got := r.Header.Get(htmx.HeaderHXPrompt + "-URI-AutoEncoded"); got != map[bool]string{true: "true"}[tt.encoded] {
+				t.Errorf("%s-URI-AutoEncoded = %q, want encoded %v", htmx.HeaderHXPrompt, got, tt.encoded)
+			}
+
+			ctx := htmx.NewRequest(r).HTMX()
+			for name, got := range map[string]string{
+				"Prompt":      ctx.Prompt,
+				"Target":      ctx.Target,
+				"TriggerID":   ctx.TriggerID,
+				"TriggerName": ctx.TriggerName,
+			} {
+				if got != tt.value {
+					t.Errorf("HTMX().%s = %q, want %q", name, got, tt.value)
+				}
+			}
+			if !ctx.Request || ctx.Type != htmx.RequestTypePartial {
+				t.Errorf("HTMX() = %+v, want a partial htmx request", ctx)
+			}
+		})
+	}
+}
+
+func TestWithCurrentURLEncoding(t *testing.T) {
+	r := NewRequest(http.MethodGet, "/", nil, WithCurrentURL("http://app.test/crème?q=brûlée"))
+	current, ok := htmx.NewRequest(r).HTMXCurrentURL()
+	if !ok || current.Path != "/crème" || current.Query().Get("q") != "brûlée" {
+		t.Errorf("HTMXCurrentURL() = %v, %v, want http://app.test/crème?q=brûlée", current, ok)
+	}
+}
+
+func TestWithHeaderReplacesEncoding(t *testing.T) {
+	r := NewRequest(http.MethodGet, "/", nil, WithPrompt("ü"), WithPrompt("plain"))
+	if got := r.Header.Get(htmx.HeaderHXPrompt + "-URI-AutoEncoded"); got != "" {
+		t.Errorf("%s-URI-AutoEncoded = %q after a plain value, want none", htmx.HeaderHXPrompt, got)
+	}
+	if got := htmx.NewRequest(r).HTMX().Prompt; got != "plain" {
+		t.Errorf("HTMX().Prompt = %q, want %q", got, "plain")
+	}
+}
+
+func TestRequestTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		r    *http.Request
+		want htmx.RequestType
+	}{
+		{name: "page", r: NewPageRequest(http.MethodGet, "/", nil), want: htmx.RequestTypeStandard},
+		{name: "partial", r: NewRequest(http.MethodGet, "/", nil), want: htmx.RequestTypePartial},
+		{name: "boosted", r: NewRequest(http.MethodGet, "/", nil, WithBoosted()), want: htmx.RequestTypeBoosted},
+		{name: "history restore", r: NewRequest(http.MethodGet, "/", nil, WithHistoryRestore()), want: htmx.RequestTypeHistoryRestore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := htmx.NewRequest(tt.r).HTMX().Type; got != tt.want {
+				t.Errorf("HTMX().Type = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,553 @@
+package htmxtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selector is a compiled CSS selector, matching the elements of a parsed html
+// document. The supported syntax is:
+//   - type, universal, id, class and attribute selectors, with the attribute
+//     operators =, ~=, |=, ^=, $= and *=, and the case-insensitive flag i
+//   - the descendant, child (>), next sibling (+) and subsequent sibling (~)
+//     combinators
+//   - the :first-child, :last-child, :only-child, :nth-child(), :nth-last-child(),
+//     :first-of-type, :last-of-type, :nth-of-type(), :nth-last-of-type(), :empty,
+//     :root, :checked, :disabled and :not() pseudo-classes; as in browsers,
+//     :disabled matches the controls of a disabled fieldset
+//   - selector lists separated by commas
+type Selector struct {
+	source string
+	list   []complexSelector
+}
+
+// complexSelector is a sequence of compound selectors joined by combinators;
+// combinators[i] joins compounds[i] and compounds[i+1].
+type complexSelector struct {
+	compounds   []compoundSelector
+	combinators []byte
+}
+
+// compoundSelector matches elements satisfying every condition.
+type compoundSelector struct {
+	source     string
+	conditions []func(*Node) bool
+}
+
+// CompileSelector compiles the CSS selector, returning an error if the
+// selector is malformed or unsupported.
+func CompileSelector(selector string) (Selector, error) {
+	s := selectorParser{src: selector}
+	list, err := s.parseList()
+	if err == nil && s.pos < len(s.src) {
+		err = s.errorf("unexpected %q", s.src[s.pos])
+	}
+	if err != nil {
+		return Selector{}, err
+	}
+	return Selector{source: selector, list: list}, nil
+}
+
+// MustCompileSelector compiles the CSS selector, and panics if the selector is
+// malformed or unsupported.
+func MustCompileSelector(selector string) Selector {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the source of the selector.
+func (s Selector) String() string {
+	return s.source
+}
+
+// Match returns true if the element matches the selector.
+func (s Selector) Match(n *Node) bool {
+	if n.Type != ElementNode {
+		return false
+	}
+	for _, c := range s.list {
+		if c.match(n, len(c.compounds)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryAll returns the descendants of the node matching the selector, in
+// document order.
+func (s Selector) QueryAll(root *Node) []*Node {
+	var matches []*Node
+	for _, child := range root.Children {
+		child.walk(func(n *Node) {
+			if s.Match(n) {
+				matches = append(matches, n)
+			}
+		})
+	}
+	return matches
+}
+
+// QuerySelectorAll returns the descendants of the node matching the CSS
+// selector, in document order. It panics if the selector is malformed.
+func (n *Node) QuerySelectorAll(selector string) []*Node {
+	return MustCompileSelector(selector).QueryAll(n)
+}
+
+// QuerySelector returns the first descendant of the node matching the CSS
+// selector, or nil if none does. It panics if the selector is malformed.
+func (n *Node) QuerySelector(selector string) *Node {
+	if matches := n.QuerySelectorAll(selector); len(matches) > 0 {
+		return matches[0]
+	}
+	return nil
+}
+
+// match returns true if the element matches the complex selector up to the
+// compound at index i.
+func (c complexSelector) match(n *Node, i int) bool {
+	if !c.compounds[i].match(n) {
+		return false
+	} else if i == 0 {
+		return true
+	}
+
+	switch c.combinators[i-1] {
+	case '>':
+		parent := n.Parent
+		return parent != nil && parent.Type == ElementNode && c.match(parent, i-1)
+	case '+':
+		prev := previousElement(n)
+		return prev != nil && c.match(prev, i-1)
+	case '~':
+		for prev := previousElement(n); prev != nil; prev = previousElement(prev) {
+			if c.match(prev, i-1) {
+				return true
+			}
+		}
+	default:
+		for parent := n.Parent; parent != nil && parent.Type == ElementNode; parent = parent.Parent {
+			if c.match(parent, i-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c compoundSelector) match(n *Node) bool {
+	for _, condition := range c.conditions {
+		if !condition(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func previousElement(n *Node) *Node {
+	if n.Parent == nil {
+		return nil
+	}
+	var prev *Node
+	for _, sibling := range n.Parent.Children {
+		if sibling == n {
+			return prev
+		} else if sibling.Type == ElementNode {
+			prev = sibling
+		}
+	}
+	return nil
+}
+
+// position returns the 1-based position of the element among the element
+// children of its parent accepted by same, counted from the start or the end.
+func position(n *Node, fromEnd bool, same func(*Node) bool) int {
+	if n.Parent == nil {
+		return 1
+	}
+	siblings := n.Parent.Elements()
+	pos := 0
+	for i := range siblings {
+		sibling := siblings[i]
+		if fromEnd {
+			sibling = siblings[len(siblings)-1-i]
+		}
+		if same(sibling) {
+			pos++
+		}
+		if sibling == n {
+			return pos
+		}
+	}
+	return pos
+}
+
+type selectorParser struct {
+	src string
+	pos int
+}
+
+func (s *selectorParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("htmxtest: invalid selector %q at offset %d: %s", s.src, s.pos, fmt.Sprintf(format, args...))
+}
+
+func (s *selectorParser) skipSpace() bool {
+	start := s.pos
+	for s.pos < len(s.src) && isSpace(s.src[s.pos]) {
+		s.pos++
+	}
+	return s.pos > start
+}
+
+func (s *selectorParser) peek() byte {
+	if s.pos < len(s.src) {
+		return s.src[s.pos]
+	}
+	return 0
+}
+
+func (s *selectorParser) parseList() ([]complexSelector, error) {
+	var list []complexSelector
+	for {
+		s.skipSpace()
+		c, err := s.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+		s.skipSpace()
+		if s.peek() != ',' {
+			return list, nil
+		}
+		s.pos++
+	}
+}
+
+func (s *selectorParser) parseComplex() (complexSelector, error) {
+	var c complexSelector
+	for {
+		compound, err := s.parseCompound()
+		if err != nil {
+			return c, err
+		}
+		c.compounds = append(c.compounds, compound)
+
+		space := s.skipSpace()
+		combinator := byte(' ')
+		switch s.peek() {
+		case '>', '+', '~':
+			combinator = s.peek()
+			s.pos++
+			s.skipSpace()
+		case 0, ',', ')':
+			return c, nil
+		default:
+			if !space {
+				return c, s.errorf("unexpected %q", s.peek())
+			}
+		}
+		c.combinators = append(c.combinators, combinator)
+	}
+}
+
+func (s *selectorParser) parseCompound() (compoundSelector, error) {
+	start := s.pos
+	var conditions []func(*Node) bool
+
+	if s.peek() == '*' {
+		s.pos++
+	} else if name := s.parseIdent(); name != "" {
+		name = strings.ToLower(name)
+		conditions = append(conditions, func(n *Node) bool { return n.Data == name })
+	}
+
+	for {
+		var condition func(*Node) bool
+		var err error
+		switch s.peek() {
+		case '#':
+			s.pos++
+			id := s.parseIdent()
+			if id == "" {
+				return compoundSelector{}, s.errorf("missing id")
+			}
+			condition = func(n *Node) bool { return n.ID() == id }
+		case '.':
+			s.pos++
+			class := s.parseIdent()
+			if class == "" {
+				return compoundSelector{}, s.errorf("missing class")
+			}
+			condition = func(n *Node) bool { return n.HasClass(class) }
+		case '[':
+			condition, err = s.parseAttribute()
+		case ':':
+			condition, err = s.parsePseudo()
+		default:
+			if s.pos == start {
+				if s.pos == len(s.src) {
+					return compoundSelector{}, s.errorf("missing selector")
+				}
+				return compoundSelector{}, s.errorf("unexpected %q", s.peek())
+			}
+			return compoundSelector{source: s.src[start:s.pos], conditions: conditions}, nil
+		}
+		if err != nil {
+			return compoundSelector{}, err
+		}
+		conditions = append(conditions, condition)
+	}
+}
+
+// parseIdent parses an identifier, unescaping backslash escapes.
+func (s *selectorParser) parseIdent() string {
+	var b strings.Builder
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '\\' && s.pos+1 < len(s.src):
+			b.WriteByte(s.src[s.pos+1])
+			s.pos += 2
+		case c == '-' || c == '_' || c >= 0x80 || isLetter(c) || '0' <= c && c <= '9':
+			b.WriteByte(c)
+			s.pos++
+		default:
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+func (s *selectorParser) parseAttribute() (func(*Node) bool, error) {
+	s.pos++
+	s.skipSpace()
+	name := strings.ToLower(s.parseIdent())
+	if name == "" {
+		return nil, s.errorf("missing attribute name")
+	}
+	s.skipSpace()
+
+	if s.peek() == ']' {
+		s.pos++
+		return func(n *Node) bool { _, ok := n.Attr(name); return ok }, nil
+	}
+
+	op := ""
+	if strings.IndexByte("~|^$*", s.peek()) >= 0 {
+		op = s.src[s.pos : s.pos+1]
+		s.pos++
+	}
+	if s.peek() != '=' {
+		return nil, s.errorf("invalid attribute operator")
+	}
+	s.pos++
+	s.skipSpace()
+
+	var value string
+	if quote := s.peek(); quote == '"' || quote == '\'' {
+		end := strings.IndexByte(s.src[s.pos+1:], quote)
+		if end < 0 {
+			return nil, s.errorf("unterminated string")
+		}
+		value = s.src[s.pos+1 : s.pos+1+end]
+		s.pos += end + 2
+	} else if value = s.parseIdent(); value == "" {
+		return nil, s.errorf("missing attribute value")
+	}
+	s.skipSpace()
+
+	fold := false
+	if c := s.peek(); c == 'i' || c == 'I' {
+		fold = true
+		s.pos++
+		s.skipSpace()
+	}
+	if s.peek() != ']' {
+		return nil, s.errorf("unterminated attribute selector")
+	}
+	s.pos++
+
+	if fold {
+		value = strings.ToLower(value)
+	}
+	return func(n *Node) bool {
+		attr, ok := n.Attr(name)
+		if !ok {
+			return false
+		} else if fold {
+			attr = strings.ToLower(attr)
+		}
+		switch op {
+		case "~":
+			for _, word := range strings.Fields(attr) {
+				if word == value {
+					return true
+				}
+			}
+			return false
+		case "|":
+			return attr == value || strings.HasPrefix(attr, value+"-")
+		case "^":
+			return value != "" && strings.HasPrefix(attr, value)
+		case "$":
+			return value != "" && strings.HasSuffix(attr, value)
+		case "*":
+			return value != "" && strings.Contains(attr, value)
+		}
+		return attr == value
+	}, nil
+}
+
+func (s *selectorParser) parsePseudo() (func(*Node) bool, error) {
+	s.pos++
+	name := strings.ToLower(s.parseIdent())
+	anyElement := func(*Node) bool { return true }
+	sameType := func(n *Node) func(*Node) bool {
+		return func(sibling *Node) bool { return sibling.Data == n.Data }
+	}
+
+	switch name {
+	case "first-child":
+		return func(n *Node) bool { return position(n, false, anyElement) == 1 }, nil
+	case "last-child":
+		return func(n *Node) bool { return position(n, true, anyElement) == 1 }, nil
+	case "only-child":
+		return func(n *Node) bool {
+			return position(n, false, anyElement) == 1 && position(n, true, anyElement) == 1
+		}, nil
+	case "first-of-type":
+		return func(n *Node) bool { return position(n, false, sameType(n)) == 1 }, nil
+	case "last-of-type":
+		return func(n *Node) bool { return position(n, true, sameType(n)) == 1 }, nil
+	case "empty":
+		return func(n *Node) bool {
+			for _, child := range n.Children {
+				if child.Type == ElementNode || child.Type == TextNode {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "root":
+		return func(n *Node) bool { return n.Parent == nil || n.Parent.Type == DocumentNode }, nil
+	case "checked":
+		return func(n *Node) bool {
+			_, checked := n.Attr("checked")
+			_, selected := n.Attr("selected")
+			return (n.Data == "input" && checked) || (n.Data == "option" && selected)
+		}, nil
+	case "disabled":
+		return isDisabled, nil
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		a, b, err := s.parseNth()
+		if err != nil {
+			return nil, err
+		}
+		fromEnd := strings.Contains(name, "last")
+		ofType := strings.HasSuffix(name, "of-type")
+		return func(n *Node) bool {
+			same := anyElement
+			if ofType {
+				same = sameType(n)
+			}
+			return nthMatch(a, b, position(n, fromEnd, same))
+		}, nil
+	case "not":
+		if s.peek() != '(' {
+			return nil, s.errorf("missing argument of :not()")
+		}
+		s.pos++
+		list, err := s.parseList()
+		if err != nil {
+			return nil, err
+		}
+		if s.peek() != ')' {
+			return nil, s.errorf("unterminated :not()")
+		}
+		s.pos++
+		inner := Selector{list: list}
+		return func(n *Node) bool { return !inner.Match(n) }, nil
+	case "":
+		return nil, s.errorf("missing pseudo-class")
+	}
+	return nil, s.errorf("unsupported pseudo-class :%s", name)
+}
+
+// parseNth parses the an+b argument of the nth pseudo-classes.
+func (s *selectorParser) parseNth() (a, b int, err error) {
+	if s.peek() != '(' {
+		return 0, 0, s.errorf("missing argument")
+	}
+	end := strings.IndexByte(s.src[s.pos:], ')')
+	if end < 0 {
+		return 0, 0, s.errorf("unterminated argument")
+	}
+	arg := strings.ToLower(strings.Join(strings.Fields(s.src[s.pos+1:s.pos+end]), ""))
+	s.pos += end + 1
+
+	switch arg {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+
+	n := strings.IndexByte(arg, 'n')
+	if n < 0 {
+		b, err = strconv.Atoi(arg)
+		if err != nil {
+			return 0, 0, s.errorf("invalid argument %q", arg)
+		}
+		return 0, b, nil
+	}
+
+	switch coefficient := arg[:n]; coefficient {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		if a, err = strconv.Atoi(coefficient); err != nil {
+			return 0, 0, s.errorf("invalid argument %q", arg)
+		}
+	}
+	if offset := strings.TrimPrefix(arg[n+1:], "+"); offset != "" {
+		if b, err = strconv.Atoi(offset); err != nil {
+			return 0, 0, s.errorf("invalid argument %q", arg)
+		}
+	}
+	return a, b, nil
+}
+
+// nthMatch returns true if pos equals a*k+b for some integer k >= 0.
+func nthMatch(a, b, pos int) bool {
+	if a == 0 {
+		return pos == b
+	}
+	k := (pos - b) / a
+	return (pos-b)%a == 0 && k >= 0
+}
+
+// formControls are the elements that may be disabled.
+var formControls = setOf("button", "input", "select", "textarea", "optgroup", "option", "fieldset")
+
+// isDisabled returns true if the form control has the disabled attribute, or
+// belongs to a disabled fieldset outside of its first legend.
+func isDisabled(n *Node) bool {
+	if !formControls[n.Data] {
+		return false
+	} else if _, ok := n.Attr("disabled"); ok {
+		return true
+	}
+	for child, parent := n, n.Parent; parent != nil; child, parent = parent, parent.Parent {
+		_, ok := parent.Attr("disabled")
+		firstLegend := child.Data == "legend" && position(child, false, func(n *Node) bool { return n.Data == "legend" }) == 1
+		if ok && parent.Data == "fieldset" && !firstLegend {
+			return true
+		}
+	}
+	return false
+}
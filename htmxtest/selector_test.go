@@ -0,0 +1,156 @@
+package htmxtest
+
+import (
+	"strings"
+	"testing"
+)
+
+const selectorFixture = `<main id="main">
+	<h1 id="title" class="heading big">Snippets</h1>
+	<p id="intro" lang="en-US" data-kind="Intro Text">intro</p>
+	<ul id="list">
+		<li id="l1" class="item first"><a id="a1" href="https://example.com/one.pdf">one</a></li>
+		<li id="l2" class="item"><a id="a2" href="/two">two</a></li>
+		<li id="l3" class="item done"></li>
+		<li id="l4" class="item"><!-- only a comment --></li>
+	</ul>
+	<form id="form">
+		<input id="i1" type="checkbox" checked>
+		<input id="i2" type="checkbox">
+		<input id="i3" type="text" disabled>
+		<select id="s1"><option id="o1">a</option><option id="o2" selected>b</option></select>
+		<fieldset id="fs" disabled><legend id="lg"><input id="i5"></legend><input id="i4"></fieldset>
+		<button id="b1">send</button>
+	</form>
+	<div id="d1"><span id="sp1">x</span><em id="em1">y</em><span id="sp2">z</span></div>
+</main>`
+
+func TestQueryAll(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		// simple selectors
+		{"li", "l1 l2 l3 l4"},
+		{"*", "main title intro list l1 a1 l2 a2 l3 l4 form i1 i2 i3 s1 o1 o2 fs lg i5 i4 b1 d1 sp1 em1 sp2"},
+		{"#l2", "l2"},
+		{".item.done", "l3"},
+		{"LI.first", "l1"},
+		{"h1, #intro, h1", "title intro"},
+
+		// attribute selectors
+		{"[checked]", "i1"},
+		{"[type=checkbox]", "i1 i2"},
+		{`[type="text"]`, "i3"},
+		{"[class~=item]", "l1 l2 l3 l4"},
+		{"[class~=ite]", ""},
+		{"[lang|=en]", "intro"},
+		{"[href^=https]", "a1"},
+		{`[href$=".pdf"]`, "a1"},
+		{"[href*=two]", "a2"},
+		{"[data-kind='intro text' i]", "intro"},
+		{"[data-kind='intro text']", ""},
+
+		// combinators
+		{"ul a", "a1 a2"},
+		{"main > li", ""},
+		{"ul > li > a", "a1 a2"},
+		{"#l1 + li", "l2"},
+		{"#l1 ~ li", "l2 l3 l4"},
+		{"h1 + p ~ ul > li:last-child", "l4"},
+		{"#sp1 + span", ""},
+		{"#sp1 ~ span", "sp2"},
+
+		// structural pseudo-classes
+		{"li:first-child", "l1"},
+		{"li:last-child", "l4"},
+		{"a:only-child", "a1 a2"},
+		{"li:nth-child(2)", "l2"},
+		{"li:nth-child(odd)", "l1 l3"},
+		{"li:nth-child(even)", "l2 l4"},
+		{"li:nth-child(2n+1)", "l1 l3"},
+		{"li:nth-child(-n+2)", "l1 l2"},
+		{"li:nth-last-child(1)", "l4"},
+		{"#d1 > :nth-of-type(2)", "sp2"},
+		{"#d1 > :nth-last-of-type(1)", "em1 sp2"},
+		{"#d1 span:first-of-type", "sp1"},
+		{"#d1 span:last-of-type", "sp2"},
+		{"li:empty", "l3 l4"},
+		{":root", "main"},
+
+		// form pseudo-classes
+		{":checked", "i1 o2"},
+		{":disabled", "i3 fs i4"},
+		{"input:not(:disabled)", "i1 i2 i5"},
+		{"li:not(.first, .done)", "l2 l4"},
+	}
+
+	doc, err := ParseHTML(selectorFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			s, err := CompileSelector(tt.selector)
+			if err != nil {
+				t.Fatalf("CompileSelector() error = %v", err)
+			}
+			if got := ids(s.QueryAll(doc)); got != tt.want {
+				t.Errorf("QueryAll() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileSelectorErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"div,",
+		"> div",
+		"div >",
+		"div > > p",
+		"#",
+		".",
+		"[href",
+		"[href=]",
+		"[href='x]",
+		"[href==x]",
+		"a:hover",
+		"li:nth-child(x)",
+		"li:nth-child(2",
+		":not()",
+		"div::before",
+		"div {",
+	}
+
+	for _, selector := range tests {
+		t.Run(selector, func(t *testing.T) {
+			if _, err := CompileSelector(selector); err == nil {
+				t.Errorf("CompileSelector(%q) succeeded, want an error", selector)
+			}
+		})
+	}
+}
+
+func TestSelectorMatch(t *testing.T) {
+	doc, err := ParseHTML(selectorFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := MustCompileSelector("ul > .item")
+	li := doc.QuerySelector("#l2")
+	if !s.Match(li) || s.Match(li.Parent) || s.Match(li.Children[0]) || s.Match(doc) {
+		t.Error("Match() differs from the selector")
+	}
+	if got := doc.QuerySelector(".missing"); got != nil {
+		t.Errorf("QuerySelector() = %v, want nil", got)
+	}
+}
+
+func ids(nodes []*Node) string {
+	ids := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		ids = append(ids, n.ID())
+	}
+	return strings.Join(ids, " ")
+}
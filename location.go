@@ -0,0 +1,180 @@
+package htmx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidLocation is returned when a location cannot be encoded into the
+// "HX-Location" response header.
+var ErrInvalidLocation = errors.New("htmx: invalid location")
+
+// Location describes a client-side redirect performed by htmx via the
+// "HX-Location" response header. Only Path is required; the remaining fields
+// mirror the options of the htmx.ajax() javascript api.
+//   - https://htmx.org/headers/hx-location/
+//
+// For example, to load "/snippets" into the "#main" element after a form
+// submission:
+//
+//	w.SetLocationOptionsHeader(htmx.Location{
+//		Path:   "/snippets",
+//		Target: "#main",
+//		Swap:   htmx.NewSwap(htmx.SwapInnerHTML),
+//		Values: map[string]any{"created": id},
+//	})
+type Location struct {
+	// The url path to load the response from.
+	Path string
+	// CSS selector of the source element of the request.
+	Source string
+	// An event that "triggered" the request.
+	Event string
+	// A callback that will handle the response HTML.
+	Handler string
+	// CSS selector of the element to swap the response into.
+	Target string
+	// How the response will be swapped in relative to the target.
+	Swap Swap
+	// Values to submit with the request.
+	Values map[string]any
+	// Headers to submit with the request.
+	Headers map[string]string
+	// CSS selector choosing which part of the response is swapped in.
+	Select string
+}
+
+type locationJSON struct {
+	Path    string            `json:"path"`
+	Source  string            `json:"source,omitempty"`
+	Event   string            `json:"event,omitempty"`
+	Handler string            `json:"handler,omitempty"`
+	Target  string            `json:"target,omitempty"`
+	Swap    string            `json:"swap,omitempty"`
+	Values  map[string]any    `json:"values,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Select  string            `json:"select,omitempty"`
+}
+
+// hasOptions returns true if any field other than the path is set.
+func (l Location) hasOptions() bool {
+	return l.Source != "" ||
+		l.Event != "" ||
+		l.Handler != "" ||
+		l.Target != "" ||
+		l.Swap != (Swap{}) ||
+		len(l.Values) > 0 ||
+		len(l.Headers) > 0 ||
+		l.Select != ""
+}
+
+// Validate reports whether the location can be encoded into the "HX-Location"
+// header. The returned error wraps ErrInvalidLocation, or ErrInvalidSwap if
+// the swap specification is invalid. The source, target and select selectors
+// receive the same sanity checks as SetRetargetHeader().
+func (l Location) Validate() error {
+	if l.Path == "" {
+		return fmt.Errorf("%w: missing path", ErrInvalidLocation)
+	}
+	selectors := map[string]string{"source": l.Source, "target": l.Target, "select": l.Select}
+	for _, field := range []string{"source", "target", "select"} {
+		if selectors[field] == "" {
+			continue
+		} else if problem := selectorProblem(selectors[field]); problem != "" {
+			return fmt.Errorf("%w: %s selector %s", ErrInvalidLocation, field, problem)
+		}
+	}
+	if l.Swap != (Swap{}) {
+		return l.Swap.Validate()
+	}
+	return nil
+}
+
+// MarshalJSON encodes the location into the JSON object form of the
+// "HX-Location" header.
+func (l Location) MarshalJSON() ([]byte, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	value := locationJSON{
+		Path:    l.Path,
+		Source:  l.Source,
+		Event:   l.Event,
+		Handler: l.Handler,
+		Target:  l.Target,
+		Values:  l.Values,
+		Headers: l.Headers,
+		Select:  l.Select,
+	}
+	if l.Swap != (Swap{}) {
+		value.Swap = l.Swap.String()
+	}
+	return json.Marshal(value)
+}
+
+// UnmarshalJSON decodes the JSON object form of the "HX-Location" header.
+func (l *Location) UnmarshalJSON(data []byte) error {
+	var value locationJSON
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLocation, err)
+	}
+
+	location := Location{
+		Path:    value.Path,
+		Source:  value.Source,
+		Event:   value.Event,
+		Handler: value.Handler,
+		Target:  value.Target,
+		Values:  value.Values,
+		Headers: value.Headers,
+		Select:  value.Select,
+	}
+	if value.Swap != "" {
+		swap, err := ParseSwap(value.Swap)
+		if err != nil {
+			return err
+		}
+		location.Swap = swap
+	}
+	if err := location.Validate(); err != nil {
+		return err
+	}
+
+	*l = location
+	return nil
+}
+
+// ParseLocation parses the value of the "HX-Location" header, either a plain
+// path or a JSON object. The returned error wraps ErrInvalidLocation, or
+// ErrInvalidSwap if the swap specification is invalid.
+func ParseLocation(value string) (Location, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "{") {
+		location := Location{Path: value}
+		if err := location.Validate(); err != nil {
+			return Location{}, err
+		}
+		return location, nil
+	}
+
+	var location Location
+	if err := location.UnmarshalJSON([]byte(value)); err != nil {
+		return Location{}, err
+	}
+	return location, nil
+}
+
+func (l Location) headerValue() (string, error) {
+	if err := l.Validate(); err != nil {
+		return "", err
+	} else if !l.hasOptions() {
+		return l.Path, nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidLocation, err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,123 @@
+package longpoll
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nisimpson/htmx"
+)
+
+const (
+	// VersionParam is the name of the request parameter in which long polling
+	// elements report the version of the resource they display.
+	VersionParam = "htmx-version"
+
+	// TimeoutEvent is triggered on the long polling element when no change
+	// occurs before the timeout, causing it to poll again without a swap.
+	TimeoutEvent = "longpoll:timeout"
+
+	// DefaultTimeout is how long handlers without a configured timeout wait
+	// for a change.
+	DefaultTimeout = 30 * time.Second
+)
+
+// Handler responds to long polling requests, blocking until the resource
+// changes or the timeout expires. Changes are answered by rendering the
+// component within a new long polling element, which immediately polls again
+// for the next change; timeouts are answered with "204 No Content", triggering
+// the element to poll again without swapping anything. For example:
+//
+//	hub := longpoll.NewHub()
+//	store := storage.NewMemoryStorage()
+//	store.Notifier = hub
+//
+//	mux.Handle("/snippets/changes", htmx.HTMX(longpoll.Handler{
+//		Hub: hub,
+//		Key: "snippets",
+//		Render: func(r *htmx.Request, version uint64) htmx.Component {
+//			return snippetsList(r.Context())
+//		},
+//	}))
+//
+// along with the initial markup rendered with Component().
+type Handler struct {
+	// The hub tracking the resource.
+	Hub *Hub
+	// The key of the resource.
+	Key string
+	// Renders the resource at the version.
+	Render func(r *htmx.Request, version uint64) htmx.Component
+	// The url polled. Defaults to the path of the request.
+	URL string
+	// How long to wait for a change. Defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// ServeHTMX waits for a change to the resource. Requests without a version,
+// such as the first one made by an element, are answered immediately.
+func (h Handler) ServeHTMX(w *htmx.ResponseWriter, r *htmx.Request) {
+	url := h.URL
+	if url == "" {
+		url = r.URL.Path
+	}
+
+	since, err := strconv.ParseUint(r.FormValue(VersionParam), 10, 64)
+	if err != nil {
+		version := h.Hub.Version(h.Key)
+		htmx.WriteComponent(w, Component(url, version, h.Render(r, version)), http.StatusOK)
+		return
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	version, err := h.Hub.Wait(ctx, h.Key, since)
+	switch {
+	case r.Context().Err() != nil:
+		// the client went away.
+		return
+	case err != nil:
+		w.SetTriggerHeader(htmx.TriggerEvents(TimeoutEvent))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	htmx.WriteComponent(w, Component(url, version, h.Render(r, version)), http.StatusOK)
+}
+
+// ServeHTTP waits for a change to the resource.
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	htmx.HTMX(h).ServeHTTP(w, r)
+}
+
+// Component renders the content at the version within an element long polling
+// the url for the next version. The element replaces itself with each change.
+func Component(url string, version uint64, content htmx.Component) htmx.Component {
+	return htmx.ComponentFunc(func(w io.Writer) error {
+		vals, _ := json.Marshal(map[string]string{VersionParam: strconv.FormatUint(version, 10)})
+
+		buf := bytes.Buffer{}
+		buf.WriteString(`<div hx-get="` + html.EscapeString(url) + `"`)
+		buf.WriteString(` hx-trigger="load, ` + TimeoutEvent + `"`)
+		buf.WriteString(` hx-target="this" hx-swap="outerHTML"`)
+		buf.WriteString(` hx-vals="` + html.EscapeString(string(vals)) + `">`)
+		if content != nil {
+			if err := content.RenderHTMX(&buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</div>")
+		_, err := buf.WriteTo(w)
+		return err
+	})
+}
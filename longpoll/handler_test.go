@@ -0,0 +1,87 @@
+package longpoll
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/htmx"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		notify  bool
+		cancel  bool
+		status  int
+		body    string
+		trigger string
+	}{
+		{
+			name:   "first request",
+			query:  "",
+			status: http.StatusOK,
+			body:   `<div hx-get="/changes" hx-trigger="load, longpoll:timeout" hx-target="this" hx-swap="outerHTML" hx-vals="{&#34;htmx-version&#34;:&#34;0&#34;}">v0</div>`,
+		},
+		{
+			name:   "change",
+			query:  "?htmx-version=0",
+			notify: true,
+			status: http.StatusOK,
+			body:   `<div hx-get="/changes" hx-trigger="load, longpoll:timeout" hx-target="this" hx-swap="outerHTML" hx-vals="{&#34;htmx-version&#34;:&#34;1&#34;}">v1</div>`,
+		},
+		{
+			name:    "timeout",
+			query:   "?htmx-version=0",
+			status:  http.StatusNoContent,
+			trigger: TimeoutEvent,
+		},
+		{
+			name:   "client gone",
+			query:  "?htmx-version=0",
+			cancel: true,
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			h := Handler{
+				Hub: hub,
+				Key: "snippets",
+				Render: func(r *htmx.Request, version uint64) htmx.Component {
+					return htmx.ComponentFunc(func(w io.Writer) error {
+						_, err := io.WriteString(w, "v"+strconv.FormatUint(version, 10))
+						return err
+					})
+				},
+				Timeout: 20 * time.Millisecond,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			if tt.notify {
+				// the change precedes the wait, which returns at once.
+				hub.Notify("snippets")
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/changes"+tt.query, nil).WithContext(ctx))
+
+			if rec.Code != tt.status || rec.Body.String() != tt.body {
+				t.Errorf("response = %d %q, want %d %q", rec.Code, rec.Body.String(), tt.status, tt.body)
+			}
+			if got := rec.Header().Get(htmx.HeaderHXTrigger); got != tt.trigger {
+				t.Errorf("%s = %q, want %q", htmx.HeaderHXTrigger, got, tt.trigger)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Package longpoll implements long polling of versioned resources, as an
+// alternative to server sent events where streaming responses are not
+// supported.
+package longpoll
+
+import (
+	"context"
+	"sync"
+)
+
+// Notifier is implemented by types signaling that a resource changed. Stores
+// accept a Notifier to announce their writes to waiting clients.
+type Notifier interface {
+	// Notify records a change to the resource, returning its new version.
+	Notify(key string) uint64
+}
+
+// Hub tracks the version of named resources, waking the clients waiting on a
+// resource whenever it changes. Waiting does not start any goroutines, so any
+// number of clients may wait concurrently, and a waiter leaves no trace once
+// its context is done. A Hub is safe for concurrent use.
+type Hub struct {
+	mu        sync.Mutex
+	resources map[string]*resource
+}
+
+type resource struct {
+	version uint64
+	// closed, and replaced, whenever the version changes.
+	changed chan struct{}
+}
+
+// NewHub creates a hub where every resource starts at version zero.
+func NewHub() *Hub {
+	return &Hub{resources: make(map[string]*resource)}
+}
+
+// resource returns the resource with the key, creating it if needed. The
+// caller must hold the lock.
+func (h *Hub) resource(key string) *resource {
+	r, ok := h.resources[key]
+	if !ok {
+		r = &resource{changed: make(chan struct{})}
+		h.resources[key] = r
+	}
+	return r
+}
+
+// Notify records a change to the resource, waking every waiter, and returns
+// the new version of the resource.
+func (h *Hub) Notify(key string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.resource(key)
+	r.version++
+	close(r.changed)
+	r.changed = make(chan struct{})
+	return r.version
+}
+
+// Version returns the current version of the resource.
+func (h *Hub) Version(key string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.resource(key).version
+}
+
+// Wait blocks until the version of the resource differs from since, returning
+// the current version. If the context is done first, the context error is
+// returned.
+func (h *Hub) Wait(ctx context.Context, key string, since uint64) (uint64, error) {
+	for {
+		h.mu.Lock()
+		r := h.resource(key)
+		version, changed := r.version, r.changed
+		h.mu.Unlock()
+
+		if version != since {
+			return version, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return version, ctx.Err()
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package longpoll
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type waitResult struct {
+	version uint64
+	err     error
+}
+
+// wait waits in the background, returning the channel receiving the result.
+func wait(ctx context.Context, h *Hub, key string, since uint64) <-chan waitResult {
+	results := make(chan waitResult, 1)
+	go func() {
+		version, err := h.Wait(ctx, key, since)
+		results <- waitResult{version, err}
+	}()
+	return results
+}
+
+func TestHubWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		since   uint64
+		timeout time.Duration
+		cancel  bool
+		notify  []string
+		want    waitResult
+	}{
+		{
+			name:   "woken by a change",
+			notify: []string{"snippets"},
+			want:   waitResult{version: 1},
+		},
+		{
+			name:  "stale version",
+			since: 5,
+			want:  waitResult{version: 0},
+		},
+		{
+			name:    "change to another resource",
+			timeout: 20 * time.Millisecond,
+			notify:  []string{"users"},
+			want:    waitResult{version: 0, err: context.DeadlineExceeded},
+		},
+		{
+			name:    "timeout",
+			timeout: 20 * time.Millisecond,
+			want:    waitResult{version: 0, err: context.DeadlineExceeded},
+		},
+		{
+			name:   "canceled",
+			cancel: true,
+			want:   waitResult{version: 0, err: context.Canceled},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			results := wait(ctx, h, "snippets", tt.since)
+			for _, key := range tt.notify {
+				h.Notify(key)
+			}
+			if tt.cancel {
+				cancel()
+			}
+
+			select {
+			case got := <-results:
+				if got.version != tt.want.version || !errors.Is(got.err, tt.want.err) || (got.err == nil) != (tt.want.err == nil) {
+					t.Errorf("Wait() = %d, %v, want %d, %v", got.version, got.err, tt.want.version, tt.want.err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Wait() did not return")
+			}
+		})
+	}
+}
+
+func TestHubNotify(t *testing.T) {
+	h := NewHub()
+	if got := h.Version("snippets"); got != 0 {
+		t.Errorf("Version() = %d, want 0", got)
+	}
+	for want := uint64(1); want <= 3; want++ {
+		if got := h.Notify("snippets"); got != want {
+			t.Errorf("Notify() = %d, want %d", got, want)
+		}
+	}
+	if got := h.Version("snippets"); got != 3 {
+		t.Errorf("Version() = %d, want 3", got)
+	}
+	if got := h.Version("users"); got != 0 {
+		t.Errorf("Version() of another resource = %d, want 0", got)
+	}
+}
+
+func TestHubConcurrentWaiters(t *testing.T) {
+	const waiters = 200
+	h := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// half of the waiters are woken by the change, the others leave when their
+	// context is canceled.
+	var wg sync.WaitGroup
+	woken := make(chan waitResult, waiters)
+	left := make(chan waitResult, waiters)
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				version, err := h.Wait(context.Background(), "snippets", 0)
+				woken <- waitResult{version, err}
+			} else {
+				version, err := h.Wait(ctx, "users", 0)
+				left <- waitResult{version, err}
+			}
+		}(i)
+	}
+
+	// let the waiters block, although Wait also returns to waiters arriving
+	// after the change.
+	time.Sleep(10 * time.Millisecond)
+	h.Notify("snippets")
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiters did not return")
+	}
+
+	close(woken)
+	close(left)
+	for got := range woken {
+		if got.version != 1 || got.err != nil {
+			t.Errorf("woken waiter got %d, %v, want 1, nil", got.version, got.err)
+		}
+	}
+	for got := range left {
+		if got.version != 0 || !errors.Is(got.err, context.Canceled) {
+			t.Errorf("canceled waiter got %d, %v, want 0, context.Canceled", got.version, got.err)
+		}
+	}
+
+	// waiters leave nothing behind but the state of the resources.
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.resources) != 2 {
+		t.Errorf("hub tracks %d resources, want 2", len(h.resources))
+	}
+	for key, r := range h.resources {
+		select {
+		case <-r.changed:
+			t.Errorf("resource %q holds a closed channel", key)
+		default:
+		}
+	}
+}
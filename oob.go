@@ -0,0 +1,377 @@
+package htmx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// ErrInvalidOOB is returned when an out of band component cannot be marked
+// for swapping.
+var ErrInvalidOOB = errors.New("htmx: invalid out of band component")
+
+// OOBComponent is a component swapped "out of band" into an element other than
+// the target of the request, alongside the primary response content.
+//   - https://htmx.org/attributes/hx-swap-oob/
+type OOBComponent struct {
+	Component
+	// How the content is swapped relative to the target element. Defaults to
+	// SwapOuterHTML.
+	Swap SwapStyle
+	// CSS selector of the element receiving the swap. When empty, the rendered
+	// content must be a single element whose id attribute identifies the
+	// target; for swap styles other than outerHTML, its children are swapped.
+	Target string
+}
+
+// OOB creates an out of band component that swaps the rendered content
+// relative to the element matching the target CSS selector. For example,
+//
+//	htmx.OOB(row, htmx.SwapBeforeEnd, "#list")
+//
+// appends the rendered row to the "#list" element. If the target is empty,
+// the id of the rendered root element identifies the target instead.
+func OOB(component Component, swap SwapStyle, target string) OOBComponent {
+	return OOBComponent{Component: component, Swap: swap, Target: target}
+}
+
+// WithOOB composes the primary component with any number of out of band
+// components into a single component. Each out of band component is rendered
+// after the primary content and marked with the "hx-swap-oob" attribute: the
+// attribute is added to the root element of the rendered content when the
+// element itself is swapped, otherwise the content is wrapped in an element
+// carrying the attribute. Table parts, such as rows, cannot stand on their own:
+// they are placed within a <template> tag, whose content htmx searches for out
+// of band elements, and wrapped in their table section, such as <tbody>, when
+// their children are swapped. The composed response is rendered into
+// a buffer, and nothing is written if any component fails to render:
+//
+//	htmx.WriteComponent(w, htmx.WithOOB(form,
+//		htmx.OOB(row, htmx.SwapBeforeEnd, "#snippets tbody"),
+//		htmx.OOB(counter, htmx.SwapOuterHTML, ""),
+//		htmx.OOB(flash, htmx.SwapInnerHTML, "#flash"),
+//	), http.StatusOK)
+//
+// The primary component may be nil when the response consists only of out of
+// band content.
+func WithOOB(primary Component, oob ...OOBComponent) Component {
+	return oobResponse{primary: primary, oob: oob}
+}
+
+type oobResponse struct {
+	primary Component
+	oob     []OOBComponent
+}
+
+func (o oobResponse) RenderHTMX(w io.Writer) error {
+	buf := bytes.Buffer{}
+	if o.primary != nil {
+		if err := o.primary.RenderHTMX(&buf); err != nil {
+			return err
+		}
+	}
+	for _, component := range o.oob {
+		if err := component.RenderHTMX(&buf); err != nil {
+			return err
+		}
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// RenderHTMX renders the component, marked with the "hx-swap-oob" attribute.
+func (o OOBComponent) RenderHTMX(w io.Writer) error {
+	style := o.Swap
+	if style == "" {
+		style = SwapOuterHTML
+	}
+	if !style.valid() {
+		return fmt.Errorf("%w: unknown swap style %q", ErrInvalidOOB, style)
+	}
+	if o.Target != "" {
+		if problem := selectorProblem(o.Target); problem != "" {
+			return fmt.Errorf("%w: target selector %s", ErrInvalidOOB, problem)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	if o.Component != nil {
+		if err := o.Component.RenderHTMX(&buf); err != nil {
+			return err
+		}
+	}
+
+	value := string(style)
+	if o.Target != "" {
+		value += ":" + o.Target
+	} else if style == SwapOuterHTML {
+		value = "true"
+	}
+	attr := ` hx-swap-oob="` + html.EscapeString(value) + `"`
+	content := buf.Bytes()
+
+	// the element itself is the swapped content, or identifies the target.
+	if style == SwapOuterHTML || o.Target == "" {
+		root, err := scanRootElement(content)
+		if err != nil {
+			return err
+		}
+		marked := make([]byte, 0, len(content)+len(attr))
+		marked = append(marked, content[:root.nameEnd]...)
+		marked = append(marked, attr...)
+		marked = append(marked, content[root.nameEnd:]...)
+		if tableParent(root.name) != "" {
+			_, err = fmt.Fprintf(w, "<template>%s</template>", marked)
+		} else {
+			_, err = w.Write(marked)
+		}
+		return err
+	}
+
+	// otherwise the children of the wrapper are swapped.
+	first, ok := scanTag(string(content), skipInsignificant(string(content), 0))
+	if parent := tableParent(first.name); ok && !first.closing && parent != "" {
+		_, err := fmt.Fprintf(w, "<template><%s%s>%s</%s></template>", parent, attr, content, parent)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "<div%s>%s</div>", attr, content)
+	return err
+}
+
+// tableParent returns the element required to contain the table part, which
+// the html parser discards elsewhere, or an empty string if the element may
+// stand on its own.
+func tableParent(name string) string {
+	switch name {
+	case "tr":
+		return "tbody"
+	case "td", "th":
+		return "tr"
+	case "col":
+		return "colgroup"
+	case "thead", "tbody", "tfoot", "colgroup", "caption":
+		return "table"
+	}
+	return ""
+}
+
+// rootElement describes the single root element of an html fragment.
+type rootElement struct {
+	// the lowercase tag name.
+	name string
+	// the offset just past the tag name within the start tag, where
+	// attributes may be inserted.
+	nameEnd int
+}
+
+// scanRootElement verifies that the html fragment consists of a single root
+// element, ignoring surrounding whitespace and comments. Tags other than the
+// root tag may omit their end tags, as permitted by html.
+func scanRootElement(content []byte) (rootElement, error) {
+	s := string(content)
+	i := skipInsignificant(s, 0)
+	if i >= len(s) {
+		return rootElement{}, fmt.Errorf("%w: content is empty", ErrInvalidOOB)
+	}
+
+	tag, ok := scanTag(s, i)
+	if !ok || tag.closing {
+		return rootElement{}, fmt.Errorf("%w: content must begin with an element", ErrInvalidOOB)
+	}
+	if tag.hasAttr("hx-swap-oob") {
+		return rootElement{}, fmt.Errorf("%w: <%s> already has an hx-swap-oob attribute", ErrInvalidOOB, tag.name)
+	}
+
+	root := rootElement{name: tag.name, nameEnd: tag.nameEnd}
+	i = tag.end
+	if !tag.selfClosing && !isVoidElement(tag.name) {
+		if i = scanElementEnd(s, i, tag.name); i < 0 {
+			return rootElement{}, fmt.Errorf("%w: <%s> is not closed", ErrInvalidOOB, tag.name)
+		}
+	}
+
+	if i = skipInsignificant(s, i); i < len(s) {
+		return rootElement{}, fmt.Errorf("%w: content must be a single element", ErrInvalidOOB)
+	}
+	return root, nil
+}
+
+// scanElementEnd returns the offset just past the end tag closing the element
+// with the provided name, starting after its start tag, or -1 if the element
+// is not closed.
+func scanElementEnd(s string, i int, name string) int {
+	if isRawTextElement(name) {
+		end := strings.Index(strings.ToLower(s[i:]), "</"+name)
+		if end < 0 {
+			return -1
+		}
+		if tag, ok := scanTag(s, i+end); ok {
+			return tag.end
+		}
+		return -1
+	}
+
+	depth := 1
+	for i < len(s) {
+		next := strings.IndexByte(s[i:], '<')
+		if next < 0 {
+			return -1
+		}
+		i += next
+		if strings.HasPrefix(s[i:], "<!--") {
+			end := strings.Index(s[i:], "-->")
+			if end < 0 {
+				return -1
+			}
+			i += end + len("-->")
+			continue
+		}
+
+		tag, ok := scanTag(s, i)
+		if !ok {
+			i++
+			continue
+		}
+		i = tag.end
+
+		switch {
+		case tag.name != name && !tag.closing && isRawTextElement(tag.name):
+			if i = scanElementEnd(s, i, tag.name); i < 0 {
+				return -1
+			}
+		case tag.name != name || tag.selfClosing:
+		case tag.closing:
+			if depth--; depth == 0 {
+				return i
+			}
+		default:
+			depth++
+		}
+	}
+	return -1
+}
+
+// skipInsignificant returns the offset of the first character that is not
+// whitespace or part of a comment.
+func skipInsignificant(s string, i int) int {
+	for i < len(s) {
+		switch {
+		case strings.ContainsRune(" \t\r\n\f", rune(s[i])):
+			i++
+		case strings.HasPrefix(s[i:], "<!--"):
+			end := strings.Index(s[i:], "-->")
+			if end < 0 {
+				return len(s)
+			}
+			i += end + len("-->")
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+type htmlTag struct {
+	name        string
+	attrs       []string
+	nameEnd     int
+	end         int
+	closing     bool
+	selfClosing bool
+}
+
+func (t htmlTag) hasAttr(name string) bool {
+	for _, attr := range t.attrs {
+		if attr == name {
+			return true
+		}
+	}
+	return false
+}
+
+// scanTag reads the start or end tag beginning at offset i.
+func scanTag(s string, i int) (htmlTag, bool) {
+	tag := htmlTag{}
+	if i >= len(s) || s[i] != '<' {
+		return tag, false
+	}
+	i++
+	if i < len(s) && s[i] == '/' {
+		tag.closing = true
+		i++
+	}
+
+	start := i
+	for i < len(s) && isTagNameChar(s[i]) {
+		i++
+	}
+	if i == start || !isASCIILetter(s[start]) {
+		return tag, false
+	}
+	tag.name = strings.ToLower(s[start:i])
+	tag.nameEnd = i
+
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == '>':
+			tag.end = i + 1
+			return tag, true
+		case c == '/' && i+1 < len(s) && s[i+1] == '>':
+			tag.selfClosing = true
+			tag.end = i + 2
+			return tag, true
+		case c == '=':
+			// skip the attribute value, which may be quoted.
+			for i++; i < len(s) && strings.ContainsRune(" \t\r\n\f", rune(s[i])); i++ {
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				end := strings.IndexByte(s[i+1:], s[i])
+				if end < 0 {
+					return tag, false
+				}
+				i += end + 2
+				continue
+			}
+			for i < len(s) && !strings.ContainsRune(" \t\r\n\f>", rune(s[i])) {
+				i++
+			}
+		case strings.ContainsRune(" \t\r\n\f/\"'", rune(c)):
+			i++
+		default:
+			attrStart := i
+			for i < len(s) && !strings.ContainsRune(" \t\r\n\f/=>", rune(s[i])) {
+				i++
+			}
+			tag.attrs = append(tag.attrs, strings.ToLower(s[attrStart:i]))
+		}
+	}
+	return tag, false
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isTagNameChar(c byte) bool {
+	return isASCIILetter(c) || ('0' <= c && c <= '9') || c == '-' || c == ':'
+}
+
+func isVoidElement(name string) bool {
+	switch name {
+	case "area", "base", "br", "col", "embed", "hr", "img", "input",
+		"link", "meta", "source", "track", "wbr":
+		return true
+	}
+	return false
+}
+
+func isRawTextElement(name string) bool {
+	switch name {
+	case "script", "style", "textarea", "title":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package htmx
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Layout wraps the content of a page into a full html document.
+type Layout interface {
+	Wrap(content Component) Component
+}
+
+// The LayoutFunc type is an adapter to allow the use of ordinary functions as
+// layouts. If f is a function with the appropriate signature, LayoutFunc(f) is a
+// Layout that calls f.
+type LayoutFunc func(content Component) Component
+
+// Wrap calls f(content).
+func (f LayoutFunc) Wrap(content Component) Component {
+	return f(content)
+}
+
+// Page renders its content either as a fragment or as a full html document,
+// depending on how the request was made. htmx requests swapping a fragment
+// receive only the content; standard requests, boosted requests and history
+// restore requests receive the content wrapped in the layout.
+type Page struct {
+	// The content of the page.
+	Content Component
+	// The layout wrapping the content into a full html document. If nil, the
+	// content is always rendered on its own.
+	Layout Layout
+}
+
+// For returns the component to render in response to the request.
+func (p Page) For(r *Request) Component {
+	if p.Layout == nil || r.HTMX().Type == RequestTypePartial {
+		return p.Content
+	}
+	return p.Layout.Wrap(p.Content)
+}
+
+// ServeHTMX writes the page with a 200 OK status code.
+func (p Page) ServeHTMX(w *ResponseWriter, r *Request) {
+	WritePage(w, r, p, http.StatusOK)
+}
+
+// WritePage writes the component chosen by the page for the request to the
+// http response writer. Since the shape of the response depends on the htmx
+// request headers, they are added to the "Vary" response header to prevent
+// shared caches from serving a fragment in place of a full document, and vice
+// versa.
+func WritePage(w http.ResponseWriter, r *Request, page Page, status int) {
+	addVary(w.Header(), HeaderHXRequest, HeaderHXBoosted, HeaderHXHistoryRestoreRequest)
+	WriteComponent(w, page.For(r), status)
+}
+
+// addVary adds the header keys to the "Vary" response header, unless they are
+// already present.
+func addVary(h http.Header, keys ...string) {
+	existing := map[string]bool{}
+	for _, value := range h.Values("Vary") {
+		for _, key := range strings.Split(value, ",") {
+			existing[http.CanonicalHeaderKey(strings.TrimSpace(key))] = true
+		}
+	}
+	for _, key := range keys {
+		if !existing[http.CanonicalHeaderKey(key)] && !existing["*"] {
+			existing[http.CanonicalHeaderKey(key)] = true
+			h.Add("Vary", key)
+		}
+	}
+}
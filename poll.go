@@ -0,0 +1,171 @@
+package htmx
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusStopPolling is the status code that stops the polling of the
+// requesting element. The response content is still swapped in.
+//   - https://htmx.org/docs/#polling
+const StatusStopPolling = 286
+
+// PollIntervalParam is the name of the request parameter in which polling
+// elements rendered by a Poller report their current interval, in milliseconds.
+const PollIntervalParam = "htmx-poll-interval"
+
+// PollResult describes the state of a polled resource.
+type PollResult int
+
+const (
+	// The resource changed since the last poll; polling continues at the
+	// base interval.
+	PollChanged PollResult = iota
+	// The resource did not change since the last poll; the interval is
+	// increased, up to the maximum interval.
+	PollUnchanged
+	// The resource reached a terminal state; polling stops once the final
+	// content is swapped in.
+	PollDone
+)
+
+// Poller renders content within an element that polls the server at an
+// interval chosen by the server. Each poll response replaces the element, so
+// the interval may grow while nothing changes, and polling stops for good once
+// the polled resource reaches a terminal state. For example, a job status page:
+//
+//	poller := htmx.Poller{ID: "job", URL: "/jobs/42", Interval: time.Second, MaxInterval: 30 * time.Second}
+//
+//	func (s *Server) jobStatus(w *htmx.ResponseWriter, r *htmx.Request) {
+//		job := s.jobs.Get("42")
+//		switch {
+//		case job.Finished():
+//			poller.Poll(w, r, jobView{job}, htmx.PollDone)
+//		case job.UpdatedSince(r):
+//			poller.Poll(w, r, jobView{job}, htmx.PollChanged)
+//		default:
+//			poller.Poll(w, r, jobView{job}, htmx.PollUnchanged)
+//		}
+//	}
+type Poller struct {
+	// The id attribute of the polling element, if any.
+	ID string
+	// The url polled. Defaults to the path of the poll request.
+	URL string
+	// The base interval between polls. Defaults to one second.
+	Interval time.Duration
+	// The largest interval between polls reached by backing off. Defaults to
+	// the base interval, which disables backing off.
+	MaxInterval time.Duration
+	// The factor the interval is multiplied by for each unchanged poll.
+	// Defaults to 2.
+	Backoff float64
+}
+
+func (p Poller) interval() time.Duration {
+	if p.Interval <= 0 {
+		return time.Second
+	}
+	return p.Interval.Truncate(time.Millisecond)
+}
+
+func (p Poller) maxInterval() time.Duration {
+	if p.MaxInterval < p.interval() {
+		return p.interval()
+	}
+	return p.MaxInterval.Truncate(time.Millisecond)
+}
+
+// Component renders the content within an element polling the url at the
+// base interval, for use within the initial page.
+func (p Poller) Component(content Component) Component {
+	return p.wrap(content, p.URL, p.interval())
+}
+
+// Poll responds to a poll request made by a polling element. Changed content is
+// swapped in and polling continues at the base interval. Unchanged content
+// increases the interval by the backoff factor; once the maximum interval is
+// reached, nothing is swapped and polling continues as before. Once done, the
+// final content is swapped in without the polling attributes, using the
+// StatusStopPolling status code.
+func (p Poller) Poll(w http.ResponseWriter, r *Request, content Component, result PollResult) {
+	url := p.URL
+	if url == "" {
+		url = r.URL.Path
+	}
+
+	switch result {
+	case PollDone:
+		WriteComponent(w, p.wrap(content, url, 0), StatusStopPolling)
+	case PollUnchanged:
+		current := p.currentInterval(r)
+		next := p.nextInterval(current)
+		if next == current {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		WriteComponent(w, p.wrap(content, url, next), http.StatusOK)
+	default:
+		WriteComponent(w, p.wrap(content, url, p.interval()), http.StatusOK)
+	}
+}
+
+// currentInterval returns the interval reported by the polling element,
+// bounded by the configured intervals.
+func (p Poller) currentInterval(r *Request) time.Duration {
+	ms, err := strconv.ParseInt(r.FormValue(PollIntervalParam), 10, 64)
+	current := time.Duration(ms) * time.Millisecond
+	if err != nil || current < p.interval() {
+		return p.interval()
+	} else if current > p.maxInterval() {
+		return p.maxInterval()
+	}
+	return current
+}
+
+func (p Poller) nextInterval(current time.Duration) time.Duration {
+	backoff := p.Backoff
+	if backoff <= 1 {
+		backoff = 2
+	}
+	next := time.Duration(float64(current) * backoff).Truncate(time.Millisecond)
+	if next > p.maxInterval() {
+		return p.maxInterval()
+	}
+	return next
+}
+
+// wrap renders the content within the polling element. An interval of zero
+// renders the element without polling attributes.
+func (p Poller) wrap(content Component, url string, interval time.Duration) Component {
+	return ComponentFunc(func(w io.Writer) error {
+		buf := bytes.Buffer{}
+		buf.WriteString("<div")
+		if p.ID != "" {
+			buf.WriteString(` id="` + html.EscapeString(p.ID) + `"`)
+		}
+		if interval > 0 {
+			vals, _ := json.Marshal(map[string]string{
+				PollIntervalParam: strconv.FormatInt(interval.Milliseconds(), 10),
+			})
+			buf.WriteString(` hx-get="` + html.EscapeString(url) + `"`)
+			buf.WriteString(` hx-trigger="every ` + formatSwapDelay(interval) + `"`)
+			buf.WriteString(` hx-target="this" hx-swap="outerHTML"`)
+			buf.WriteString(` hx-vals="` + html.EscapeString(string(vals)) + `"`)
+		}
+		buf.WriteString(">")
+		if content != nil {
+			if err := content.RenderHTMX(&buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</div>")
+		_, err := buf.WriteTo(w)
+		return err
+	})
+}
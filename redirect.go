@@ -0,0 +1,174 @@
+package htmx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrUnsafeRedirect is returned when a redirect target is refused by the
+// redirect policy.
+var ErrUnsafeRedirect = errors.New("htmx: unsafe redirect")
+
+// RedirectPolicy decides which urls clients may be sent to, preventing
+// handlers that redirect to user input, such as a "?next=" parameter, from
+// becoming open redirectors. The zero value only allows relative urls, and
+// absolute urls to the host of the request. For example:
+//
+//	handler := htmx.HTMX(h, htmx.WithRedirectPolicy(htmx.RedirectPolicy{
+//		AllowedHosts: []string{"accounts.example.com", "*.example.com"},
+//		Fallback:     "/",
+//	}))
+//
+// Targets using a scheme other than http and https, such as "javascript:",
+// targets containing backslashes, which browsers treat as slashes, and targets
+// starting with three or more slashes, which browsers treat as absolute urls,
+// are always refused.
+type RedirectPolicy struct {
+	// Hosts allowed on top of the host of the request. A leading "*." matches
+	// any subdomain, but not the domain itself; any other "*" is matched
+	// literally. Ports are ignored.
+	AllowedHosts []string
+	// Refuses every absolute url, including urls to the host of the request.
+	RelativeOnly bool
+	// Compares targets against the host of the page that issued the request,
+	// found in the "HX-Current-URL" header, rather than the host of the
+	// request. Useful behind proxies that rewrite the host of requests.
+	TrustCurrentURL bool
+	// The url unsafe targets are replaced with. When empty, unsafe targets
+	// are refused with ErrUnsafeRedirect.
+	Fallback string
+}
+
+type redirectPolicyContextKey struct{}
+
+// ContextWithRedirectPolicy returns a copy of the parent context carrying the
+// redirect policy. The HTMX() handler wrapper attaches the policy configured
+// with WithRedirectPolicy() automatically.
+func ContextWithRedirectPolicy(parent context.Context, policy RedirectPolicy) context.Context {
+	return context.WithValue(parent, redirectPolicyContextKey{}, policy)
+}
+
+// RedirectPolicyFromContext returns the redirect policy attached to the
+// context, if any.
+func RedirectPolicyFromContext(ctx context.Context) (RedirectPolicy, bool) {
+	policy, ok := ctx.Value(redirectPolicyContextKey{}).(RedirectPolicy)
+	return policy, ok
+}
+
+// Check returns the target if the policy allows sending clients of the request
+// to it. Otherwise, the fallback is returned if set, or an error wrapping
+// ErrUnsafeRedirect.
+func (p RedirectPolicy) Check(r *http.Request, target string) (string, error) {
+	problem := p.problem(r, target)
+	if problem == "" {
+		return target, nil
+	} else if p.Fallback != "" {
+		return p.Fallback, nil
+	}
+	return "", fmt.Errorf("%w: %q %s", ErrUnsafeRedirect, target, problem)
+}
+
+// problem returns the reason the target is refused, or an empty string if the
+// target is allowed.
+func (p RedirectPolicy) problem(r *http.Request, target string) string {
+	if strings.ContainsRune(target, '\\') {
+		return "contains a backslash"
+	}
+	for _, c := range target {
+		if c < ' ' || c == 0x7f {
+			return "contains control characters"
+		}
+	}
+	if strings.TrimSpace(target) != target {
+		return "has surrounding whitespace"
+	}
+
+	u, err := url.Parse(target)
+	switch {
+	case err != nil:
+		return "is malformed"
+	case u.Scheme != "" && !strings.EqualFold(u.Scheme, "http") && !strings.EqualFold(u.Scheme, "https"):
+		return "has a scheme other than http and https"
+	case u.Opaque != "" || (u.Scheme != "" && u.Host == ""):
+		return "is malformed"
+	case u.Host == "" && strings.HasPrefix(target, "//"):
+		// browsers resolve "///host" as an absolute url to the host.
+		return "starts with more than two slashes"
+	case u.Host == "":
+		return ""
+	case p.RelativeOnly:
+		return "is not relative"
+	case strings.EqualFold(u.Host, p.origin(r)):
+		return ""
+	}
+
+	hostname := strings.ToLower(u.Hostname())
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		// the dot of the wildcard keeps look-alike hosts, such as
+		// "evilexample.com", from matching "*.example.com".
+		if domain, ok := strings.CutPrefix(allowed, "*."); ok && strings.HasSuffix(hostname, "."+domain) {
+			return ""
+		} else if hostname == allowed {
+			return ""
+		}
+	}
+	return "has a host that is not allowed"
+}
+
+// origin returns the host targets are compared against.
+func (p RedirectPolicy) origin(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	if p.TrustCurrentURL {
+		if current, ok := NewRequest(r).HTMXCurrentURL(); ok && current.Host != "" {
+			return current.Host
+		}
+	}
+	return r.Host
+}
+
+// Redirect replies to the request with a redirect to the target, as
+// http.Redirect() does, after checking the target against the policy. No
+// response is written if the target is refused.
+func (p RedirectPolicy) Redirect(w http.ResponseWriter, r *http.Request, target string, code int) error {
+	target, err := p.Check(r, target)
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, target, code)
+	return nil
+}
+
+// Redirect replies to the request with a redirect to the target, as
+// http.Redirect() does, after checking the target against the redirect policy
+// attached to the request context; see WithRedirectPolicy(). Requests without
+// a policy are checked against the zero RedirectPolicy, which only allows
+// relative urls and urls to the host of the request. No response is written
+// if the target is refused.
+//
+// The redirect is followed transparently by htmx requests, whose target
+// element is swapped with the response. To navigate away from the page
+// instead, use the SetRedirectHeader() method of the response writer.
+func Redirect(w http.ResponseWriter, r *http.Request, target string, code int) error {
+	policy, _ := RedirectPolicyFromContext(r.Context())
+	return policy.Redirect(w, r, target, code)
+}
+
+// checkRedirect checks the target against the redirect policy of the request
+// served by the writer. Writers without a policy allow every target.
+func (r ResponseWriter) checkRedirect(target string) (string, error) {
+	if r.state == nil || r.state.request == nil {
+		return target, nil
+	}
+	policy, ok := RedirectPolicyFromContext(r.state.request.Context())
+	if !ok {
+		return target, nil
+	}
+	return policy.Check(r.state.request.Request, target)
+}
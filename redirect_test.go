@@ -0,0 +1,111 @@
+package htmx
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRedirectPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RedirectPolicy
+		target string
+		safe   bool
+	}{
+		{name: "relative path", target: "/snippets?id=1", safe: true},
+		{name: "relative reference", target: "snippets", safe: true},
+		{name: "same host", target: "http://app.test/snippets", safe: true},
+		{name: "same host case insensitive", target: "HTTPS://APP.TEST/", safe: true},
+		{name: "other host", target: "https://evil.com"},
+		{name: "protocol relative", target: "//evil.com"},
+		{name: "three slashes", target: "///evil.com"},
+		{name: "four slashes", target: "////evil.com"},
+		{name: "backslash", target: `/\evil.com`},
+		{name: "backslashes", target: `\\evil.com`},
+		{name: "scheme without slashes", target: "https:evil.com"},
+		{name: "javascript", target: "javascript:alert(1)"},
+		{name: "data", target: "data:text/html,<script>alert(1)</script>"},
+		{name: "control characters", target: "/\t/evil.com"},
+		{name: "leading space", target: " //evil.com"},
+		{name: "allowed host", policy: RedirectPolicy{AllowedHosts: []string{"accounts.example.com"}}, target: "https://accounts.example.com/login", safe: true},
+		{name: "allowed wildcard", policy: RedirectPolicy{AllowedHosts: []string{"*.example.com"}}, target: "https://a.example.com:8443/", safe: true},
+		{name: "wildcard suffix", policy: RedirectPolicy{AllowedHosts: []string{"*.example.com"}}, target: "https://evilexample.com/"},
+		{name: "wildcard nested subdomain", policy: RedirectPolicy{AllowedHosts: []string{"*.example.com"}}, target: "https://a.b.example.com/", safe: true},
+		{name: "wildcard domain itself", policy: RedirectPolicy{AllowedHosts: []string{"*.example.com"}}, target: "https://example.com/"},
+		{name: "wildcard without dot", policy: RedirectPolicy{AllowedHosts: []string{"*example.com"}}, target: "https://evilexample.com/"},
+		{name: "wildcard without dot domain", policy: RedirectPolicy{AllowedHosts: []string{"*example.com"}}, target: "https://example.com/"},
+		{name: "relative only", policy: RedirectPolicy{RelativeOnly: true}, target: "http://app.test/"},
+		{name: "relative only path", policy: RedirectPolicy{RelativeOnly: true}, target: "/snippets", safe: true},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "http://app.test/login", nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.Check(r, tt.target)
+			if tt.safe {
+				if err != nil || got != tt.target {
+					t.Errorf("Check(%q) = %q, %v, want the target", tt.target, got, err)
+				}
+			} else if !errors.Is(err, ErrUnsafeRedirect) || got != "" {
+				t.Errorf("Check(%q) = %q, %v, want ErrUnsafeRedirect", tt.target, got, err)
+			}
+		})
+	}
+}
+
+func TestRedirectPolicyFallback(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://app.test/login", nil)
+	got, err := RedirectPolicy{Fallback: "/"}.Check(r, "///evil.com")
+	if err != nil || got != "/" {
+		t.Errorf("Check() = %q, %v, want the fallback", got, err)
+	}
+}
+
+func TestRedirectPolicyTrustCurrentURL(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://internal:8080/login", nil)
+	r.Header.Set(HeaderHXRequest, "true")
+	r.Header.Set(HeaderHXCurrentURL, "https://app.test/page")
+
+	policy := RedirectPolicy{TrustCurrentURL: true}
+	if _, err := policy.Check(r, "https://app.test/next"); err != nil {
+		t.Errorf("Check() of the current host = %v", err)
+	}
+	if _, err := policy.Check(r, "http://internal:8080/next"); err == nil {
+		t.Error("Check() of the request host succeeded, want an error")
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	h := HTMX(HandlerFunc(func(w *ResponseWriter, r *Request) {
+		if err := Redirect(w, r.Request, "///evil.com", http.StatusSeeOther); !errors.Is(err, ErrUnsafeRedirect) {
+			t.Errorf("Redirect() = %v, want ErrUnsafeRedirect", err)
+		}
+		if err := w.SetRedirectHeader(url.URL{Path: "//evil.com"}); !errors.Is(err, ErrUnsafeRedirect) {
+			t.Errorf("SetRedirectHeader() = %v, want ErrUnsafeRedirect", err)
+		}
+		if err := w.SetLocationOptionsHeader(Location{Path: "///evil.com", Target: "#main"}); !errors.Is(err, ErrUnsafeRedirect) {
+			t.Errorf("SetLocationOptionsHeader() = %v, want ErrUnsafeRedirect", err)
+		}
+		if err := w.SetPushHeader(url.URL{Path: "/snippets"}); err != nil {
+			t.Errorf("SetPushHeader() = %v", err)
+		}
+	}), WithRedirectPolicy(RedirectPolicy{}))
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	for _, header := range []string{"Location", HeaderHXRedirect, HeaderHXLocation} {
+		if value := rec.Header().Get(header); value != "" {
+			t.Errorf("%s header = %q, want none", header, value)
+		}
+	}
+	if got := rec.Header().Get(HeaderHXPushURL); got != "/snippets" {
+		t.Errorf("%s header = %q, want %q", HeaderHXPushURL, got, "/snippets")
+	}
+}
@@ -7,53 +7,168 @@ import (
 
 type Request struct {
 	*http.Request
+	htmx *HTMXContext
 }
 
+// NewRequest creates a new htmx request instance, parsing the htmx request
+// headers of the underlying http request once upfront.
 func NewRequest(r *http.Request) *Request {
-	return &Request{Request: r}
+	ctx := parseHTMXContext(r.Header)
+	return &Request{Request: r, htmx: &ctx}
+}
+
+// RequestType classifies an incoming request by how the htmx client made it,
+// which determines the shape of the response the client expects.
+type RequestType int
+
+const (
+	// The request was not made by htmx; a full html document is expected.
+	RequestTypeStandard RequestType = iota
+	// The request was made by htmx to swap a fragment into the current page.
+	RequestTypePartial
+	// The request was made by an element using "hx-boost"; htmx swaps the body
+	// of the full html document into the current page.
+	RequestTypeBoosted
+	// The request was made by htmx to restore history after a miss in the
+	// local history cache; a full html document is expected.
+	RequestTypeHistoryRestore
+)
+
+func (t RequestType) String() string {
+	switch t {
+	case RequestTypeStandard:
+		return "standard"
+	case RequestTypePartial:
+		return "partial"
+	case RequestTypeBoosted:
+		return "boosted"
+	case RequestTypeHistoryRestore:
+		return "history-restore"
+	}
+	return "unknown"
+}
+
+// HTMXContext contains the parsed values of every htmx request header.
+// Values sent URI encoded by the htmx client, as indicated by the companion
+// "*-URI-AutoEncoded" headers, are decoded.
+//   - https://htmx.org/reference/#request_headers
+type HTMXContext struct {
+	// True if the "HX-Request" header is set to "true".
+	Request bool
+	// True if the request is via an element using "hx-boost".
+	Boosted bool
+	// True if the request is for history restoration after a miss in the
+	// local history cache.
+	HistoryRestoreRequest bool
+	// The current URL of the browser, or nil if absent or malformed.
+	CurrentURL *url.URL
+	// The user response to an "hx-prompt".
+	Prompt string
+	// The id of the target element, if it exists.
+	Target string
+	// The id of the triggered element, if it exists.
+	TriggerID string
+	// The name of the triggered element, if it exists.
+	TriggerName string
+	// How the htmx client made the request.
+	Type RequestType
+}
+
+func parseHTMXContext(h http.Header) HTMXContext {
+	ctx := HTMXContext{
+		Request:               headerValue(h, HeaderHXRequest) == "true",
+		Boosted:               headerValue(h, HeaderHXBoosted) == "true",
+		HistoryRestoreRequest: headerValue(h, HeaderHXHistoryRestoreRequest) == "true",
+		Prompt:                headerValue(h, HeaderHXPrompt),
+		Target:                headerValue(h, HeaderHXTarget),
+		TriggerID:             headerValue(h, HeaderHXTrigger),
+		TriggerName:           headerValue(h, HeaderHXTriggerName),
+	}
+
+	if value := headerValue(h, HeaderHXCurrentURL); value != "" {
+		if currentURL, err := url.Parse(value); err == nil {
+			ctx.CurrentURL = currentURL
+		}
+	}
+
+	switch {
+	case !ctx.Request:
+		ctx.Type = RequestTypeStandard
+	case ctx.HistoryRestoreRequest:
+		ctx.Type = RequestTypeHistoryRestore
+	case ctx.Boosted:
+		ctx.Type = RequestTypeBoosted
+	default:
+		ctx.Type = RequestTypePartial
+	}
+
+	return ctx
+}
+
+// headerValue returns the value of the header key, decoding the value if the
+// htmx client indicates that it was URI encoded.
+func headerValue(h http.Header, key string) string {
+	value := h.Get(key)
+	if h.Get(key+headerSuffixURIAutoEncoded) != "true" {
+		return value
+	} else if decoded, err := url.PathUnescape(value); err == nil {
+		return decoded
+	}
+	return value
+}
+
+// HTMX returns the parsed htmx request headers. Requests created with
+// NewRequest() parse the headers once; otherwise they are parsed on each call.
+func (r Request) HTMX() HTMXContext {
+	if r.htmx != nil {
+		return *r.htmx
+	}
+	return parseHTMXContext(r.Header)
 }
 
 // IsHTMXRequest returns true if the "HX-Request" header key has a value of "true".
 // This indicates that the client is using the htmx library to render html.
 func (r Request) IsHTMXRequest() bool {
-	return r.Header.Get(HeaderHXRequest) == "true"
+	return r.HTMX().Request
+}
+
+// IsHTMXBoosted returns true if the "HX-Boosted" header is set to "true". This
+// indicates that the request is via an element using "hx-boost".
+func (r Request) IsHTMXBoosted() bool {
+	return r.HTMX().Boosted
 }
 
 // HTMXTriggerName returns the id of the element that triggered the
 // server request. This value is stored within the "HX-Trigger" header.
 func (r Request) HTMXTriggerID() string {
-	return r.Header.Get(HeaderHXTrigger)
+	return r.HTMX().TriggerID
 }
 
 // HTMXTriggerName returns the name of the element that triggered the
 // server request. This value is stored within the "HX-Trigger-Name" header.
 func (r Request) HTMXTriggerName() string {
-	return r.Header.Get(HeaderHXTriggerName)
+	return r.HTMX().TriggerName
 }
 
 // HTMXTargetID returns the id of the target element receiving the html
 // fragment response. This value is stored within the "HX-Target" header.
 func (r Request) HTMXTargetID() string {
-	return r.Header.Get(HeaderHXTarget)
+	return r.HTMX().Target
 }
 
 // HTMXPrompt returns the value entered by the client user when
 // prompted via the "hx-prompt" attribute. This value is stored within
 // the "HX-Prompt" header.
 func (r Request) HTMXPrompt() string {
-	return r.Header.Get(HeaderHXPrompt)
+	return r.HTMX().Prompt
 }
 
 // HTMXCurrentURL returns the current URL of the client browser if specified.
 func (r Request) HTMXCurrentURL() (*url.URL, bool) {
-	if !r.IsHTMXRequest() {
-		return nil, false
-	} else if value := r.Header.Get(HeaderHXCurrentURL); value == "" {
-		return nil, false
-	} else if currentURL, err := url.Parse(value); err != nil {
+	if ctx := r.HTMX(); !ctx.Request || ctx.CurrentURL == nil {
 		return nil, false
 	} else {
-		return currentURL, true
+		return ctx.CurrentURL, true
 	}
 }
 
@@ -61,5 +176,5 @@ func (r Request) HTMXCurrentURL() (*url.URL, bool) {
 // header is set to "true". This indicates that this request is for history restoration
 // after a miss in the local history cache.
 func (r Request) IsHTMXHistoryRestoreRequest() bool {
-	return r.Header.Get(HeaderHXHistoryRestoreRequest) == "true"
+	return r.HTMX().HistoryRestoreRequest
 }
@@ -0,0 +1,213 @@
+package sse
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/nisimpson/htmx"
+)
+
+// ErrBrokerClosed is returned when publishing to a closed broker.
+var ErrBrokerClosed = errors.New("sse: broker closed")
+
+// DefaultReplaySize is the number of events retained per topic by brokers
+// created with a non-positive replay size.
+const DefaultReplaySize = 64
+
+// subscriptionBuffer is the number of events queued for a subscriber before
+// it is considered too slow and disconnected.
+const subscriptionBuffer = 16
+
+// Broker distributes events published to topics among connected clients. Each
+// topic retains a bounded number of recent events, which are replayed to
+// clients reconnecting with the "Last-Event-ID" header. Event ids are assigned
+// by the broker and increase across all topics. A Broker is safe for concurrent
+// use.
+type Broker struct {
+	mu          sync.Mutex
+	replaySize  int
+	lastID      uint64
+	topics      map[string]*topic
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+type topic struct {
+	// recent events, oldest first.
+	events      []bufferedEvent
+	subscribers map[*Subscription]struct{}
+}
+
+type bufferedEvent struct {
+	id    uint64
+	event Event
+}
+
+// NewBroker creates a broker retaining up to replaySize events per topic.
+func NewBroker(replaySize int) *Broker {
+	if replaySize <= 0 {
+		replaySize = DefaultReplaySize
+	}
+	return &Broker{
+		replaySize:  replaySize,
+		topics:      make(map[string]*topic),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events published to one or more topics.
+type Subscription struct {
+	broker *Broker
+	topics []string
+	events chan Event
+	once   sync.Once
+}
+
+// Events returns the channel receiving published events. The channel is
+// closed when the subscription is closed, when the broker is closed, or when
+// the subscriber falls too far behind; clients may then reconnect and resume
+// from the id of the last event received.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close removes the subscription from the broker.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.unsubscribe(s)
+}
+
+// Subscribe registers a subscription to the topics. If lastEventID is the id of
+// a previously published event, the retained events published after it are
+// returned for replay, oldest first; the subscription receives only events
+// published afterwards.
+func (b *Broker) Subscribe(lastEventID string, topics ...string) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &Subscription{
+		broker: b,
+		topics: topics,
+		events: make(chan Event, subscriptionBuffer),
+	}
+	if b.closed {
+		b.unsubscribe(sub)
+		return sub, nil
+	}
+
+	b.subscribers[sub] = struct{}{}
+	for _, name := range topics {
+		b.topic(name).subscribers[sub] = struct{}{}
+	}
+	return sub, b.replay(lastEventID, topics)
+}
+
+func (b *Broker) replay(lastEventID string, topics []string) []Event {
+	since, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	var missed []bufferedEvent
+	for _, name := range topics {
+		for _, e := range b.topic(name).events {
+			if e.id > since {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	sort.Slice(missed, func(i, j int) bool { return missed[i].id < missed[j].id })
+	events := make([]Event, 0, len(missed))
+	for _, e := range missed {
+		events = append(events, e.event)
+	}
+	return events
+}
+
+// topic returns the topic with the name, creating it if needed. The caller
+// must hold the lock.
+func (b *Broker) topic(name string) *topic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &topic{subscribers: make(map[*Subscription]struct{})}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// unsubscribe removes the subscription and closes its channel. The caller
+// must hold the lock.
+func (b *Broker) unsubscribe(sub *Subscription) {
+	sub.once.Do(func() {
+		delete(b.subscribers, sub)
+		for _, name := range sub.topics {
+			if t, ok := b.topics[name]; ok {
+				delete(t.subscribers, sub)
+			}
+		}
+		close(sub.events)
+	})
+}
+
+// Publish assigns the event an id, retains it for replay, and delivers it to
+// the subscribers of the topic. Subscribers that cannot keep up are
+// disconnected rather than blocking the publisher.
+func (b *Broker) Publish(topicName string, event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+
+	b.lastID++
+	event.ID = strconv.FormatUint(b.lastID, 10)
+
+	t := b.topic(topicName)
+	t.events = append(t.events, bufferedEvent{id: b.lastID, event: event})
+	if overflow := len(t.events) - b.replaySize; overflow > 0 {
+		t.events = append(t.events[:0:0], t.events[overflow:]...)
+	}
+
+	for sub := range t.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			b.unsubscribe(sub)
+		}
+	}
+	return nil
+}
+
+// PublishComponent renders the component once and publishes it as the data
+// of the named event to the subscribers of the topic.
+func (b *Broker) PublishComponent(topic, name string, component htmx.Component) error {
+	event, err := NewEvent(name, component)
+	if err != nil {
+		return err
+	}
+	return b.Publish(topic, event)
+}
+
+// Clients returns the number of active subscriptions.
+func (b *Broker) Clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Close disconnects all subscribers. Subsequent subscriptions are closed
+// immediately, and publishing returns ErrBrokerClosed.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.unsubscribe(sub)
+	}
+}
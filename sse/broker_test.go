@@ -0,0 +1,232 @@
+package sse
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// received drains the events queued for the subscription, reporting whether
+// the subscription is still open.
+func received(sub *Subscription) (events []Event, open bool) {
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return events, false
+			}
+			events = append(events, event)
+		default:
+			return events, true
+		}
+	}
+}
+
+func eventIDs(events []Event) []string {
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestBrokerPublish(t *testing.T) {
+	b := NewBroker(0)
+	both, _ := b.Subscribe("", "snippets", "users")
+	snippets, _ := b.Subscribe("", "snippets")
+	if b.Clients() != 2 {
+		t.Fatalf("Clients() = %d, want 2", b.Clients())
+	}
+
+	for _, topic := range []string{"snippets", "users", "orders"} {
+		if err := b.Publish(topic, Event{Name: topic, Data: "<p>" + topic + "</p>"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		sub   *Subscription
+		names []string
+		ids   []string
+	}{
+		{name: "both topics", sub: both, names: []string{"snippets", "users"}, ids: []string{"1", "2"}},
+		{name: "one topic", sub: snippets, names: []string{"snippets"}, ids: []string{"1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, open := received(tt.sub)
+			if !open {
+				t.Fatal("subscription closed")
+			}
+			if len(events) != len(tt.names) {
+				t.Fatalf("received %v, want events %q", events, tt.names)
+			}
+			for i, event := range events {
+				if event.Name != tt.names[i] || event.ID != tt.ids[i] || event.Data != "<p>"+tt.names[i]+"</p>" {
+					t.Errorf("event %d = %+v, want %s with id %s", i, event, tt.names[i], tt.ids[i])
+				}
+			}
+		})
+	}
+
+	snippets.Close()
+	snippets.Close()
+	if _, open := received(snippets); open || b.Clients() != 1 {
+		t.Errorf("closed subscription open = %v, Clients() = %d, want closed and 1", open, b.Clients())
+	}
+	if err := b.Publish("snippets", Event{}); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := received(both); len(events) != 1 {
+		t.Errorf("received %v after a subscriber left, want 1 event", events)
+	}
+}
+
+func TestBrokerReplay(t *testing.T) {
+	b := NewBroker(3)
+	// ids 1 to 8, alternating between the topics.
+	for i := 1; i <= 8; i++ {
+		topic := "even"
+		if i%2 == 1 {
+			topic = "odd"
+		}
+		if err := b.Publish(topic, Event{Data: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		topics      []string
+		want        []string
+	}{
+		{name: "no last event id", topics: []string{"odd"}},
+		{name: "malformed last event id", lastEventID: "abc", topics: []string{"odd"}},
+		{name: "latest event", lastEventID: "8", topics: []string{"odd", "even"}},
+		{name: "one topic", lastEventID: "4", topics: []string{"odd"}, want: []string{"5", "7"}},
+		{name: "topics merged in order", lastEventID: "4", topics: []string{"odd", "even"}, want: []string{"5", "6", "7", "8"}},
+		{name: "bounded by the replay size", lastEventID: "0", topics: []string{"even"}, want: []string{"4", "6", "8"}},
+		{name: "unknown topic", lastEventID: "0", topics: []string{"other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay := b.Subscribe(tt.lastEventID, tt.topics...)
+			defer sub.Close()
+			if got := eventIDs(replay); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("replay = %v, want %v", got, tt.want)
+			}
+			for _, event := range replay {
+				if event.Data != event.ID {
+					t.Errorf("replayed event %+v, want data %s", event, event.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestBrokerSlowSubscriber(t *testing.T) {
+	b := NewBroker(0)
+	slow, _ := b.Subscribe("", "snippets")
+	fast, _ := b.Subscribe("", "snippets")
+
+	for i := 0; i < subscriptionBuffer+1; i++ {
+		if err := b.Publish("snippets", Event{}); err != nil {
+			t.Fatal(err)
+		}
+		if i < subscriptionBuffer {
+			received(fast)
+		}
+	}
+
+	// the queued events are still delivered before the channel closes.
+	events, open := received(slow)
+	if open || len(events) != subscriptionBuffer {
+		t.Errorf("slow subscriber received %d events, open = %v, want %d and closed", len(events), open, subscriptionBuffer)
+	}
+	if events, open := received(fast); !open || len(events) != 1 {
+		t.Errorf("fast subscriber received %d events, open = %v, want 1 and open", len(events), open)
+	}
+	if b.Clients() != 1 {
+		t.Errorf("Clients() = %d, want 1", b.Clients())
+	}
+
+	// the dropped subscriber resumes from the last event it received.
+	resumed, replay := b.Subscribe(events[len(events)-1].ID, "snippets")
+	defer resumed.Close()
+	if len(replay) != 1 || replay[0].ID != strconv.Itoa(subscriptionBuffer+1) {
+		t.Errorf("replay = %v, want the dropped event", eventIDs(replay))
+	}
+	slow.Close()
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(0)
+	sub, _ := b.Subscribe("", "snippets")
+	b.Close()
+
+	if _, open := received(sub); open {
+		t.Error("subscription open after Close()")
+	}
+	if err := b.Publish("snippets", Event{}); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Publish() = %v, want ErrBrokerClosed", err)
+	}
+	late, replay := b.Subscribe("0", "snippets")
+	if _, open := received(late); open || replay != nil {
+		t.Errorf("late subscription open = %v, replay = %v, want closed without replay", open, replay)
+	}
+	if b.Clients() != 0 {
+		t.Errorf("Clients() = %d, want 0", b.Clients())
+	}
+	sub.Close()
+	late.Close()
+}
+
+func TestBrokerConcurrent(t *testing.T) {
+	const (
+		clients = 8
+		events  = 50
+	)
+	b := NewBroker(clients * events)
+
+	var readers sync.WaitGroup
+	subs := make([]*Subscription, clients)
+	for i := range subs {
+		subs[i], _ = b.Subscribe("", "snippets")
+		readers.Add(1)
+		go func(sub *Subscription) {
+			defer readers.Done()
+			for range sub.Events() {
+			}
+		}(subs[i])
+	}
+
+	var publishers sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		publishers.Add(1)
+		go func(sub *Subscription) {
+			defer publishers.Done()
+			for j := 0; j < events; j++ {
+				b.Publish("snippets", Event{})
+			}
+			sub.Close()
+		}(subs[i])
+	}
+	publishers.Wait()
+	readers.Wait()
+
+	if b.Clients() != 0 {
+		t.Errorf("Clients() = %d, want 0", b.Clients())
+	}
+	_, replay := b.Subscribe("0", "snippets")
+	seen := make(map[string]bool)
+	for _, event := range replay {
+		seen[event.ID] = true
+	}
+	if len(replay) != clients*events || len(seen) != len(replay) {
+		t.Errorf("replayed %d events with %d distinct ids, want %d", len(replay), len(seen), clients*events)
+	}
+}
@@ -0,0 +1,88 @@
+package sse
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/nisimpson/htmx"
+)
+
+// DefaultHeartbeat is the interval between heartbeats used by handlers
+// without a configured interval.
+const DefaultHeartbeat = 30 * time.Second
+
+// Handler streams the events published to the broker topics to each connected
+// client, until the client disconnects or the broker is closed. Clients
+// reconnecting with the "Last-Event-ID" header first receive the retained
+// events they missed. For example, to replace polling with pushed updates:
+//
+//	mux.Handle("/snippets/events", sse.Handler{Broker: broker, Topics: []string{"snippets"}})
+//
+//	// after a snippet is created
+//	broker.PublishComponent("snippets", "snippets", components.SnippetsList{...})
+//
+// along with the markup:
+//
+//	<div hx-ext="sse" sse-connect="/snippets/events" sse-swap="snippets">...</div>
+type Handler struct {
+	// The broker the events are published to.
+	Broker *Broker
+	// The topics streamed to the client.
+	Topics []string
+	// The interval between heartbeat comments keeping idle connections open.
+	// Defaults to DefaultHeartbeat; a negative value disables heartbeats.
+	Heartbeat time.Duration
+}
+
+// ServeHTTP streams events to the client.
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stream, err := NewStream(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sub, replay := h.Broker.Subscribe(r.Header.Get("Last-Event-ID"), h.Topics...)
+	defer sub.Close()
+
+	for _, event := range replay {
+		if err := stream.Send(event); err != nil {
+			return
+		}
+	}
+
+	interval := h.Heartbeat
+	if interval == 0 {
+		interval = DefaultHeartbeat
+	}
+
+	var heartbeat <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if err := stream.Send(event); err != nil {
+				return
+			}
+		case <-heartbeat:
+			if err := stream.Heartbeat(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ServeHTMX streams events to the client through the htmx response writer.
+func (h Handler) ServeHTMX(w *htmx.ResponseWriter, r *htmx.Request) {
+	h.ServeHTTP(w, r.Request)
+}
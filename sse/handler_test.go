@@ -0,0 +1,101 @@
+package sse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// serve runs the handler in the background, returning a channel closed once
+// the handler returns.
+func serve(h Handler, r *http.Request) (*httptest.ResponseRecorder, <-chan struct{}) {
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(rec, r)
+	}()
+	return rec, done
+}
+
+// waitClients waits until the broker has n clients.
+func waitClients(t *testing.T, b *Broker, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for b.Clients() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Clients() = %d, want %d", b.Clients(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitDone(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return")
+	}
+}
+
+func TestHandlerContextCancel(t *testing.T) {
+	b := NewBroker(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	rec, done := serve(Handler{Broker: b, Topics: []string{"snippets"}, Heartbeat: -1}, r)
+
+	waitClients(t, b, 1)
+	if err := b.Publish("snippets", Event{Name: "snippets", Data: "<p>new</p>"}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	waitDone(t, done)
+
+	if b.Clients() != 0 {
+		t.Errorf("Clients() = %d after the request was canceled, want 0", b.Clients())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	// the event may be sent or not, depending on which case the handler
+	// selected first.
+	if body := rec.Body.String(); body != "" && body != "id: 1\nevent: snippets\ndata: <p>new</p>\n\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestHandlerReplay(t *testing.T) {
+	b := NewBroker(0)
+	for _, data := range []string{"one", "two", "three"} {
+		if err := b.Publish("snippets", Event{Data: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the request is canceled upfront, so that the handler returns after the
+	// replay.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	r.Header.Set("Last-Event-ID", "1")
+	rec, done := serve(Handler{Broker: b, Topics: []string{"snippets"}}, r)
+	waitDone(t, done)
+
+	if want := "id: 2\ndata: two\n\nid: 3\ndata: three\n\n"; rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+	if b.Clients() != 0 {
+		t.Errorf("Clients() = %d, want 0", b.Clients())
+	}
+}
+
+func TestHandlerBrokerClose(t *testing.T) {
+	b := NewBroker(0)
+	_, done := serve(Handler{Broker: b, Topics: []string{"snippets"}}, httptest.NewRequest(http.MethodGet, "/events", nil))
+	waitClients(t, b, 1)
+	b.Close()
+	waitDone(t, done)
+}
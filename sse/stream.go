@@ -0,0 +1,129 @@
+// Package sse implements server sent events for the htmx sse extension.
+//   - https://htmx.org/extensions/sse/
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nisimpson/htmx"
+)
+
+// ErrStreamingNotSupported is returned when the http response writer cannot
+// flush data to the client.
+var ErrStreamingNotSupported = errors.New("sse: streaming not supported")
+
+// Event is a single server sent event. htmx swaps the data of events whose
+// name matches the "sse-swap" attribute of an element, and triggers requests
+// on elements with a matching "hx-trigger='sse:<name>'" attribute.
+type Event struct {
+	// The id of the event, sent back by the client within the "Last-Event-ID"
+	// header when reconnecting.
+	ID string
+	// The name of the event. If empty, the client treats it as a "message".
+	Name string
+	// The data of the event, typically an html fragment.
+	Data string
+}
+
+// NewEvent creates an event with the provided name, rendering the component
+// as its data.
+func NewEvent(name string, component htmx.Component) (Event, error) {
+	buf := bytes.Buffer{}
+	if err := component.RenderHTMX(&buf); err != nil {
+		return Event{}, err
+	}
+	return Event{Name: name, Data: buf.String()}, nil
+}
+
+// WriteTo writes the event in the text/event-stream format.
+func (e Event) WriteTo(w io.Writer) (int64, error) {
+	buf := bytes.Buffer{}
+	if e.ID != "" {
+		buf.WriteString("id: " + singleLine(e.ID) + "\n")
+	}
+	if e.Name != "" {
+		buf.WriteString("event: " + singleLine(e.Name) + "\n")
+	}
+
+	data := strings.ReplaceAll(e.Data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteByte('\n')
+	return buf.WriteTo(w)
+}
+
+// singleLine strips line breaks, which would otherwise terminate the field.
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// Stream writes server sent events to a single client.
+type Stream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// NewStream prepares the response for streaming events to the client, sending
+// the response header immediately. An error wrapping ErrStreamingNotSupported
+// is returned if the response writer cannot flush data to the client; the
+// response is left untouched in that case.
+func NewStream(w http.ResponseWriter) (*Stream, error) {
+	rc := http.NewResponseController(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+
+	if err := rc.Flush(); err != nil {
+		for _, key := range []string{"Content-Type", "Cache-Control", "X-Accel-Buffering"} {
+			h.Del(key)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrStreamingNotSupported, err)
+	}
+	return &Stream{w: w, rc: rc}, nil
+}
+
+// Send writes the event and flushes it to the client.
+func (s *Stream) Send(event Event) error {
+	if _, err := event.WriteTo(s.w); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// SendComponent renders the component and sends it as the data of the named
+// event.
+func (s *Stream) SendComponent(name string, component htmx.Component) error {
+	event, err := NewEvent(name, component)
+	if err != nil {
+		return err
+	}
+	return s.Send(event)
+}
+
+// Retry instructs the client how long to wait before reconnecting after the
+// connection is lost.
+func (s *Stream) Retry(d time.Duration) error {
+	if _, err := io.WriteString(s.w, "retry: "+strconv.FormatInt(d.Milliseconds(), 10)+"\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// Heartbeat writes a comment, which the client ignores, keeping idle
+// connections from being closed by proxies.
+func (s *Stream) Heartbeat() error {
+	if _, err := io.WriteString(s.w, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
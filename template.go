@@ -0,0 +1,91 @@
+package htmx
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+)
+
+// TemplateFragment is a component backed by an html template that renders the
+// whole template for full page requests, and only a named block of the template
+// for htmx requests swapping a fragment.
+//   - https://htmx.org/essays/template-fragments/
+//
+// This allows a page and the fragments it is updated with to be kept within a
+// single template:
+//
+//	{{define "main"}}
+//	    <h2>Latest Snippets</h2>
+//	    <div id="snippets" hx-get="/" hx-trigger="every 1s">
+//	        {{block "snippets_list" .}}...{{end}}
+//	    </div>
+//	{{end}}
+//
+// The block may be chosen by the id of the target element, found in the
+// "HX-Target" request header, through the Targets mapping:
+//
+//	view := htmx.TemplateFragment{
+//		Template: ts,
+//		Name:     "home.tmpl",
+//		Data:     data,
+//		Fragment: "main",
+//		Targets:  map[string]string{"snippets": "snippets_list"},
+//	}
+//	view.ServeHTMX(w, r)
+type TemplateFragment struct {
+	// The template set containing the page and its fragments.
+	Template *template.Template
+	// The name of the template rendered for full page requests. If empty,
+	// the template set itself is executed.
+	Name string
+	// The data passed to the template when executed.
+	Data any
+	// The name of the block rendered for htmx requests whose target id is not
+	// found within Targets. If empty, the full page is rendered instead.
+	Fragment string
+	// Maps the id of the target element of htmx requests to the name of the
+	// block that is rendered.
+	Targets map[string]string
+}
+
+// For returns the component to render in response to the request.
+func (t TemplateFragment) For(r *Request) Component {
+	ctx := r.HTMX()
+	if ctx.Type != RequestTypePartial {
+		return t
+	} else if block, ok := t.Targets[ctx.Target]; ok {
+		return t.block(block)
+	} else if t.Fragment != "" {
+		return t.block(t.Fragment)
+	}
+	return t
+}
+
+// RenderHTMX renders the full page template.
+func (t TemplateFragment) RenderHTMX(w io.Writer) error {
+	if t.Name == "" {
+		return t.Template.Execute(w, t.Data)
+	}
+	return t.Template.ExecuteTemplate(w, t.Name, t.Data)
+}
+
+func (t TemplateFragment) block(name string) Component {
+	return ComponentFunc(func(w io.Writer) error {
+		if t.Template.Lookup(name) == nil {
+			return fmt.Errorf("htmx: template %q has no block %q", t.Template.Name(), name)
+		}
+		return t.Template.ExecuteTemplate(w, name, t.Data)
+	})
+}
+
+// ServeHTMX writes the component chosen for the request with a 200 OK status
+// code. The htmx request headers determining the choice are added to the
+// "Vary" response header.
+func (t TemplateFragment) ServeHTMX(w *ResponseWriter, r *Request) {
+	addVary(w.Header(), HeaderHXRequest, HeaderHXBoosted, HeaderHXHistoryRestoreRequest)
+	if len(t.Targets) > 0 {
+		addVary(w.Header(), HeaderHXTarget)
+	}
+	WriteComponent(w, t.For(r), http.StatusOK)
+}
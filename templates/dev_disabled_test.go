@@ -0,0 +1,31 @@
+//go:build !htmxdev
+
+package templates
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDevModeDisabled(t *testing.T) {
+	ts := &Set{FS: testFiles(), Pages: []string{"pages/*.tmpl"}}
+	if err := ts.Watch(context.Background(), 0, nil); !errors.Is(err, ErrDevModeDisabled) {
+		t.Errorf("Watch() = %v, want ErrDevModeDisabled", err)
+	}
+
+	reload := NewLiveReload("/_reload")
+	reload.Extension = "/static/sse.js"
+	if got := reload.HTML(); got != "" {
+		t.Errorf("HTML() = %s, want nothing", got)
+	}
+
+	rec := httptest.NewRecorder()
+	reload.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_reload", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("endpoint answered %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	reload.Reload()
+}
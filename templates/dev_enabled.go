@@ -0,0 +1,5 @@
+//go:build htmxdev
+
+package templates
+
+const devMode = true
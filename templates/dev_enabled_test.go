@@ -0,0 +1,131 @@
+//go:build htmxdev
+
+package templates
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/htmx"
+)
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchReload(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "pages", "home.tmpl"), `<p>{{template "card" "v1"}}</p>`)
+	writeFile(t, filepath.Join(dir, "partials", "card.tmpl"), `{{define "card"}}{{.}}{{end}}`)
+
+	ts := &Set{FS: os.DirFS(dir), Pages: []string{"pages/*.tmpl"}, Partials: []string{"partials/*.tmpl"}}
+	if got, err := render(t, ts, "home.tmpl", nil); err != nil || got != "<p>v1</p>" {
+		t.Fatalf("View() = %q, %v", got, err)
+	}
+
+	reload := NewLiveReload("/_reload")
+	srv := httptest.NewServer(reload)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watched := make(chan error, 1)
+	go func() { watched <- ts.Watch(ctx, 5*time.Millisecond, reload.Reload) }()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if got := res.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", got)
+	}
+	for reload.broker.Clients() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the watcher takes its first snapshot in the background; the change is
+	// repeated until it is detected.
+	writeFile(t, filepath.Join(dir, "partials", "card.tmpl"), `{{define "card"}}<b>{{.}}</b>{{end}}`)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		modTime := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			modTime = modTime.Add(time.Second)
+			os.Chtimes(filepath.Join(dir, "partials", "card.tmpl"), modTime, modTime)
+		}
+	}()
+
+	events := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				events <- name
+				return
+			}
+		}
+		close(events)
+	}()
+	select {
+	case name := <-events:
+		if name != ReloadEvent {
+			t.Fatalf("received event %q, want %q", name, ReloadEvent)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload event received")
+	}
+
+	// templates are parsed again before pages are told to reload.
+	if got, err := render(t, ts, "home.tmpl", nil); err != nil || got != "<p><b>v1</b></p>" {
+		t.Errorf("View() after the change = %q, %v, want the new partial", got, err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set(htmx.HeaderHXRequest, "true")
+	refresh, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh.Body.Close()
+	if refresh.StatusCode != http.StatusNoContent || refresh.Header.Get(htmx.HeaderHXRefresh) != "true" {
+		t.Errorf("htmx request answered %d with %s = %q, want %d and true",
+			refresh.StatusCode, htmx.HeaderHXRefresh, refresh.Header.Get(htmx.HeaderHXRefresh), http.StatusNoContent)
+	}
+
+	cancel()
+	if err := <-watched; !errors.Is(err, context.Canceled) {
+		t.Errorf("Watch() = %v, want context.Canceled", err)
+	}
+}
+
+func TestLiveReloadHTML(t *testing.T) {
+	reload := NewLiveReload("/_reload?a&b")
+	reload.Extension = "/static/sse.js"
+	want := `<script src="/static/sse.js"></script><div hx-ext="sse" sse-connect="/_reload?a&amp;b" hx-get="/_reload?a&amp;b" hx-trigger="sse:reload" hx-swap="none"></div>`
+	if got := reload.HTML(); string(got) != want {
+		t.Errorf("HTML() = %s, want %s", got, want)
+	}
+	if !DevMode {
+		t.Error("DevMode = false in a build with the htmxdev tag")
+	}
+}
@@ -0,0 +1,109 @@
+package templates
+
+import (
+	"html"
+	"html/template"
+	"io"
+	"net/http"
+
+	"github.com/nisimpson/htmx"
+	"github.com/nisimpson/htmx/sse"
+)
+
+// ReloadEvent is the name of the server sent event telling connected pages to
+// reload.
+const ReloadEvent = "reload"
+
+// reloadTopic is the broker topic reload events are published to.
+const reloadTopic = "reload"
+
+// LiveReload refreshes the pages open in connected browsers whenever templates
+// change during development. Pages connect to its endpoint with the htmx sse
+// extension; on a reload event, they request the endpoint again, which
+// replies with the "HX-Refresh" header. For example:
+//
+//	reload := templates.NewLiveReload("/_reload")
+//	ts := &templates.Set{
+//		FS:    os.DirFS("ui"),
+//		Pages: []string{"pages/*.tmpl"},
+//		Funcs: template.FuncMap{"liveReload": reload.HTML},
+//	}
+//	go ts.Watch(ctx, 0, reload.Reload)
+//	mux.Handle("/_reload", reload)
+//
+// with the layout rendering {{liveReload}} after the htmx script. LiveReload
+// only operates in builds with the "htmxdev" build tag; otherwise its endpoint
+// replies "404 Not Found" and its component renders nothing, so layouts may
+// include it unconditionally.
+type LiveReload struct {
+	// The path of the endpoint.
+	URL string
+	// The url of the htmx sse extension script, rendered along with the
+	// component when set.
+	Extension string
+
+	broker *sse.Broker
+}
+
+// NewLiveReload creates a live reload endpoint served at the url.
+func NewLiveReload(url string) *LiveReload {
+	return &LiveReload{URL: url, broker: sse.NewBroker(1)}
+}
+
+// Reload tells the connected pages to reload.
+func (l *LiveReload) Reload() {
+	if !devMode {
+		return
+	}
+	l.broker.Publish(reloadTopic, sse.Event{Name: ReloadEvent, Data: ReloadEvent})
+}
+
+// ServeHTTP streams reload events to the pages connected with the htmx sse
+// extension, and replies to htmx requests with the "HX-Refresh" header.
+func (l *LiveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !devMode {
+		http.NotFound(w, r)
+		return
+	}
+
+	if htmx.NewRequest(r).IsHTMXRequest() {
+		htmx.NewResponseWriter(w).SetRefreshHeader()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// pages reconnecting after missing a reload event receive it on
+	// reconnection; newly loaded pages do not send an event id.
+	sse.Handler{Broker: l.broker, Topics: []string{reloadTopic}}.ServeHTTP(w, r)
+}
+
+// ServeHTMX serves the endpoint through the htmx response writer.
+func (l *LiveReload) ServeHTMX(w *htmx.ResponseWriter, r *htmx.Request) {
+	l.ServeHTTP(w, r.Request)
+}
+
+// Component renders the element connecting the page to the endpoint, preceded
+// by the sse extension script if configured. Nothing is rendered unless built
+// with the "htmxdev" build tag.
+func (l *LiveReload) Component() htmx.Component {
+	return htmx.ComponentFunc(func(w io.Writer) error {
+		_, err := io.WriteString(w, string(l.HTML()))
+		return err
+	})
+}
+
+// HTML returns the markup of the component, for use as a template function.
+func (l *LiveReload) HTML() template.HTML {
+	if !devMode {
+		return ""
+	}
+
+	url := html.EscapeString(l.URL)
+	markup := ""
+	if l.Extension != "" {
+		markup += `<script src="` + html.EscapeString(l.Extension) + `"></script>`
+	}
+	markup += `<div hx-ext="sse" sse-connect="` + url + `" hx-get="` + url + `"` +
+		` hx-trigger="sse:` + ReloadEvent + `" hx-swap="none"></div>`
+	return template.HTML(markup)
+}
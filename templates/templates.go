@@ -0,0 +1,271 @@
+// Package templates loads html templates from a file system, exposing each
+// page and partial template as an htmx component. Template sets are parsed
+// lazily, on the first render of each view, and cached afterwards.
+package templates
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"text/template/parse"
+
+	"github.com/nisimpson/htmx"
+)
+
+// Set is a cache of the template sets parsed from a file system. Every page
+// template is parsed into its own set, along with the partial templates shared
+// by all pages; partial templates may also be rendered on their own, as the
+// fragments htmx requests swap into a page. For example, with pages defining
+// the "title" and "main" blocks of a "base" layout:
+//
+//	ts := &templates.Set{
+//		FS:       ui.Files, // an embed.FS
+//		Pages:    []string{"html/pages/*.tmpl"},
+//		Partials: []string{"html/components/*.tmpl"},
+//		Funcs:    template.FuncMap{"humanDate": humanDate},
+//		Layout:   "base",
+//	}
+//
+//	htmx.WriteComponent(w, ts.View("home.tmpl", data), http.StatusOK)
+//	htmx.WriteComponent(w, ts.View("snippets_list", data), http.StatusOK)
+//
+// A Set is safe for concurrent use; each template set is parsed once, no
+// matter how many requests render it concurrently. The fields of a Set must
+// not be modified after its first use.
+type Set struct {
+	// The file system the templates are loaded from.
+	FS fs.FS
+	// Glob patterns matching the page templates. Pages are named after the
+	// base name of their file, such as "home.tmpl".
+	Pages []string
+	// Glob patterns matching the partial templates parsed into every set.
+	Partials []string
+	// The functions available to every template.
+	Funcs template.FuncMap
+	// The name of the template executed for pages that only define blocks,
+	// without any content of their own. Pages with content are executed
+	// directly, and may invoke the layout themselves.
+	Layout string
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// entry is a view being parsed, or parsed, by the first request rendering it.
+type entry struct {
+	ready chan struct{}
+	view  view
+	err   error
+}
+
+// view is a template executed within a template set.
+type view struct {
+	set  *template.Template
+	name string
+}
+
+// Keys of the internal cache entries, which cannot collide with the names of
+// templates as those are never empty.
+const (
+	partialsKey = "\x00partials"
+	sharedKey   = "\x00shared"
+)
+
+// load returns the cached entry for the key, calling parse on a cache miss.
+// Concurrent callers wait for the parse started by the first caller. Failures
+// are not cached, so that the next caller parses again.
+func (s *Set) load(key string, parse func() (view, error)) (view, error) {
+	s.mu.Lock()
+	if e, ok := s.entries[key]; ok {
+		s.mu.Unlock()
+		<-e.ready
+		return e.view, e.err
+	}
+	if s.entries == nil {
+		s.entries = make(map[string]*entry)
+	}
+	e := &entry{ready: make(chan struct{})}
+	s.entries[key] = e
+	s.mu.Unlock()
+
+	e.view, e.err = parse()
+	if e.err != nil {
+		s.mu.Lock()
+		if s.entries[key] == e {
+			delete(s.entries, key)
+		}
+		s.mu.Unlock()
+	}
+	close(e.ready)
+	return e.view, e.err
+}
+
+// invalidate removes the cached views with the names, or every cached view if
+// no name is provided, so that they are parsed again on their next render.
+// Parses in progress complete, but their results are not cached.
+func (s *Set) invalidate(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(names) == 0 {
+		s.entries = nil
+		return
+	}
+	for _, name := range names {
+		delete(s.entries, name)
+	}
+}
+
+// shared returns the set of partial templates that page sets are cloned from.
+// The shared set is never executed, as executed sets cannot be cloned.
+func (s *Set) shared() (*template.Template, error) {
+	v, err := s.load(sharedKey, func() (view, error) {
+		files, err := s.glob(s.Partials)
+		if err != nil {
+			return view{}, err
+		}
+		set := template.New("").Funcs(s.Funcs)
+		for _, file := range files {
+			if err := s.parseFile(set, file); err != nil {
+				return view{}, err
+			}
+		}
+		return view{set: set}, nil
+	})
+	return v.set, err
+}
+
+// partials returns the set in which partial templates are executed.
+func (s *Set) partials() (*template.Template, error) {
+	v, err := s.load(partialsKey, func() (view, error) {
+		shared, err := s.shared()
+		if err != nil {
+			return view{}, err
+		}
+		set, err := shared.Clone()
+		return view{set: set}, err
+	})
+	return v.set, err
+}
+
+// resolve returns the view with the name, parsing its template set if needed.
+func (s *Set) resolve(name string) (view, error) {
+	return s.load(name, func() (view, error) {
+		files, err := s.glob(s.Pages)
+		if err != nil {
+			return view{}, err
+		}
+		for _, file := range files {
+			if path.Base(file) == name {
+				return s.parsePage(name, file)
+			}
+		}
+
+		set, err := s.partials()
+		if err != nil {
+			return view{}, err
+		} else if set.Lookup(name) == nil {
+			return view{}, fmt.Errorf("templates: no page or partial template named %q", name)
+		}
+		return view{set: set, name: name}, nil
+	})
+}
+
+func (s *Set) parsePage(name, file string) (view, error) {
+	shared, err := s.shared()
+	if err != nil {
+		return view{}, err
+	}
+	set, err := shared.Clone()
+	if err != nil {
+		return view{}, err
+	}
+	if err := s.parseFile(set, file); err != nil {
+		return view{}, err
+	}
+
+	// pages without content of their own are rendered through the layout.
+	page := set.Lookup(name)
+	if s.Layout == "" || page.Tree == nil || !parse.IsEmptyTree(page.Tree.Root) {
+		return view{set: set, name: name}, nil
+	} else if set.Lookup(s.Layout) == nil {
+		return view{}, fmt.Errorf("templates: page %q has no content and layout %q is not defined", name, s.Layout)
+	}
+	return view{set: set, name: s.Layout}, nil
+}
+
+// parseFile parses the file into a template named after its base name, within
+// the set.
+func (s *Set) parseFile(set *template.Template, file string) error {
+	b, err := fs.ReadFile(s.FS, file)
+	if err != nil {
+		return fmt.Errorf("templates: %w", err)
+	}
+	if _, err := set.New(path.Base(file)).Parse(string(b)); err != nil {
+		return fmt.Errorf("templates: %w", err)
+	}
+	return nil
+}
+
+// glob returns the sorted files matching any of the patterns.
+func (s *Set) glob(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(s.FS, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("templates: %w", err)
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Lookup returns the template set of the page or partial template with the
+// name, parsing it if needed. The set is shared by every render of the view,
+// and must not be modified.
+func (s *Set) Lookup(name string) (*template.Template, error) {
+	v, err := s.resolve(name)
+	return v.set, err
+}
+
+// View returns a component rendering the page or partial template with the
+// name and data. Templates are parsed on the first render of the view; parse
+// errors are returned when rendering the component.
+func (s *Set) View(name string, data any) htmx.Component {
+	return htmx.ComponentFunc(func(w io.Writer) error {
+		v, err := s.resolve(name)
+		if err != nil {
+			return err
+		}
+		return v.set.ExecuteTemplate(w, v.name, data)
+	})
+}
+
+// ParseAll parses every page template and the partial templates, reporting
+// the first error. Applications may call it on startup to fail fast, rather
+// than on the first render of an invalid template.
+func (s *Set) ParseAll() error {
+	if _, err := s.partials(); err != nil {
+		return err
+	}
+	files, err := s.glob(s.Pages)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if _, err := s.resolve(path.Base(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
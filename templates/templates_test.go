@@ -0,0 +1,186 @@
+package templates
+
+import (
+	"bytes"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// countingFS counts the files opened, which are read once per parse.
+type countingFS struct {
+	fs fs.FS
+
+	mu    sync.Mutex
+	opens map[string]int
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.mu.Lock()
+	c.opens[name]++
+	c.mu.Unlock()
+	return c.fs.Open(name)
+}
+
+func (c *countingFS) count(name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.opens[name]
+}
+
+func testFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"pages/home.tmpl":    {Data: []byte(`{{define "title"}}Home{{end}}{{define "main"}}{{template "card" .}}{{end}}`)},
+		"pages/about.tmpl":   {Data: []byte(`<p>About {{.}}</p>`)},
+		"partials/card.tmpl": {Data: []byte(`{{define "card"}}<div class="card">{{.}}</div>{{end}}`)},
+		"partials/base.tmpl": {Data: []byte(`{{define "base"}}<title>{{template "title"}}</title><main>{{template "main" .}}</main>{{end}}`)},
+	}
+}
+
+func render(t *testing.T, s *Set, name string, data any) (string, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	err := s.View(name, data).RenderHTMX(&buf)
+	return buf.String(), err
+}
+
+func TestSetView(t *testing.T) {
+	tests := []struct {
+		name string
+		view string
+		want string
+		err  string
+	}{
+		{
+			name: "page rendered through the layout",
+			view: "home.tmpl",
+			want: `<title>Home</title><main><div class="card">snail</div></main>`,
+		},
+		{
+			name: "page with content",
+			view: "about.tmpl",
+			want: `<p>About snail</p>`,
+		},
+		{
+			name: "partial",
+			view: "card",
+			want: `<div class="card">snail</div>`,
+		},
+		{
+			name: "unknown template",
+			view: "missing.tmpl",
+			err:  `templates: no page or partial template named "missing.tmpl"`,
+		},
+	}
+
+	s := &Set{FS: testFiles(), Pages: []string{"pages/*.tmpl"}, Partials: []string{"partials/*.tmpl"}, Layout: "base"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := render(t, s, tt.view, "snail")
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("View() rendered %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := s.Lookup("missing.tmpl"); err == nil {
+		t.Error("Lookup() of an unknown template succeeded")
+	}
+}
+
+func TestSetSingleFlight(t *testing.T) {
+	files := &countingFS{fs: testFiles(), opens: make(map[string]int)}
+	s := &Set{FS: files, Pages: []string{"pages/*.tmpl"}, Partials: []string{"partials/*.tmpl"}, Layout: "base"}
+
+	const renders = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, renders*2)
+	for i := 0; i < renders; i++ {
+		wg.Add(2)
+		for _, view := range []string{"home.tmpl", "card"} {
+			go func(view string, data int) {
+				defer wg.Done()
+				if _, err := render(t, s, view, data); err != nil {
+					errs <- err
+				}
+			}(view, i)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for _, file := range []string{"pages/home.tmpl", "partials/card.tmpl", "partials/base.tmpl"} {
+		if got := files.count(file); got != 1 {
+			t.Errorf("%s read %d times, want 1", file, got)
+		}
+	}
+}
+
+func TestSetInvalidate(t *testing.T) {
+	files := testFiles()
+	s := &Set{FS: files, Pages: []string{"pages/*.tmpl"}, Partials: []string{"partials/*.tmpl"}, Layout: "base"}
+	if err := s.ParseAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	files["pages/about.tmpl"] = &fstest.MapFile{Data: []byte(`<p>About us</p>`)}
+	files["partials/card.tmpl"] = &fstest.MapFile{Data: []byte(`{{define "card"}}<article>{{.}}</article>{{end}}`)}
+
+	// cached views are rendered until invalidated.
+	if got, _ := render(t, s, "about.tmpl", nil); got != "<p>About </p>" {
+		t.Errorf("cached page rendered %q", got)
+	}
+
+	s.invalidate("about.tmpl")
+	if got, _ := render(t, s, "about.tmpl", nil); got != "<p>About us</p>" {
+		t.Errorf("invalidated page rendered %q, want the new content", got)
+	}
+	if got, _ := render(t, s, "card", "x"); got != `<div class="card">x</div>` {
+		t.Errorf("partial rendered %q before invalidating every set", got)
+	}
+
+	s.invalidate()
+	if got, _ := render(t, s, "card", "x"); got != `<article>x</article>` {
+		t.Errorf("partial rendered %q, want the new content", got)
+	}
+	if got, _ := render(t, s, "home.tmpl", "x"); !strings.Contains(got, `<article>x</article>`) {
+		t.Errorf("page rendered %q, want the new partial", got)
+	}
+}
+
+func TestSetParseErrorsNotCached(t *testing.T) {
+	files := testFiles()
+	files["pages/about.tmpl"] = &fstest.MapFile{Data: []byte(`<p>{{.</p>`)}
+	s := &Set{FS: files, Pages: []string{"pages/*.tmpl"}, Partials: []string{"partials/*.tmpl"}}
+
+	if err := s.ParseAll(); err == nil {
+		t.Fatal("ParseAll() succeeded with an invalid page")
+	}
+	if _, err := render(t, s, "about.tmpl", nil); err == nil {
+		t.Fatal("View() rendered an invalid page")
+	}
+
+	files["pages/about.tmpl"] = &fstest.MapFile{Data: []byte(`<p>fixed</p>`)}
+	if got, err := render(t, s, "about.tmpl", nil); err != nil || got != "<p>fixed</p>" {
+		t.Errorf("View() = %q, %v after the page was fixed", got, err)
+	}
+}
+
+func TestSetLayoutMissing(t *testing.T) {
+	s := &Set{FS: testFiles(), Pages: []string{"pages/*.tmpl"}, Partials: []string{"partials/card.tmpl"}, Layout: "base"}
+	_, err := render(t, s, "home.tmpl", nil)
+	if err == nil || !strings.Contains(err.Error(), `layout "base" is not defined`) {
+		t.Errorf("error = %v, want an undefined layout", err)
+	}
+}
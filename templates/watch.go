@@ -0,0 +1,127 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"path"
+	"time"
+)
+
+// DevMode reports whether the package was built with the "htmxdev" build tag,
+// which enables reloading templates during development:
+//
+//	go run -tags htmxdev ./cmd/www
+//
+// Without the tag, Watch() returns ErrDevModeDisabled, and LiveReload
+// endpoints and components are inert, so that production builds cannot
+// enable reloading by accident.
+const DevMode = devMode
+
+// ErrDevModeDisabled is returned by Watch() when the package was built without
+// the "htmxdev" build tag.
+var ErrDevModeDisabled = errors.New("templates: dev mode requires the htmxdev build tag")
+
+// DefaultWatchInterval is the interval between polls of the template files
+// used by Watch() when no interval is provided.
+const DefaultWatchInterval = 500 * time.Millisecond
+
+// fileState identifies a version of a template file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	page    bool
+}
+
+// Watch polls the template files of the set for changes every interval, until
+// the context is done. Changed template sets are invalidated and parsed again,
+// logging parse errors, before calling onChange, which may be nil. Changes to
+// page files only invalidate the pages; any other change invalidates every
+// template set.
+//
+// Watch is only available in builds with the "htmxdev" build tag, and returns
+// ErrDevModeDisabled otherwise. The files must be read from a file system
+// reporting modification times, such as os.DirFS(); embedded files never
+// change.
+func (s *Set) Watch(ctx context.Context, interval time.Duration, onChange func()) error {
+	if !devMode {
+		return ErrDevModeDisabled
+	}
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	log.Printf("templates: dev mode enabled, watching templates for changes")
+	files := s.snapshot()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current := s.snapshot()
+		pages, changed := changedPages(files, current)
+		files = current
+		if !changed {
+			continue
+		}
+
+		s.invalidate(pages...)
+		if err := s.ParseAll(); err != nil {
+			log.Printf("templates: reload: %v", err)
+		}
+		if onChange != nil {
+			onChange()
+		}
+	}
+}
+
+// snapshot returns the state of the template files of the set. Files that
+// cannot be read are left out, as if they were removed.
+func (s *Set) snapshot() map[string]fileState {
+	files := make(map[string]fileState)
+	add := func(patterns []string, page bool) {
+		matches, err := s.glob(patterns)
+		if err != nil {
+			return
+		}
+		for _, file := range matches {
+			if info, err := fs.Stat(s.FS, file); err == nil {
+				files[file] = fileState{modTime: info.ModTime(), size: info.Size(), page: page}
+			}
+		}
+	}
+	add(s.Partials, false)
+	add(s.Pages, true)
+	return files
+}
+
+// changedPages compares two snapshots, returning whether any file changed,
+// along with the names of the changed pages. No names are returned when files
+// were added or removed, or when partial templates changed, since every set
+// must then be invalidated.
+func changedPages(old, current map[string]fileState) ([]string, bool) {
+	if len(old) != len(current) {
+		return nil, true
+	}
+
+	var pages []string
+	for file, state := range current {
+		previous, ok := old[file]
+		switch {
+		case !ok || previous.page != state.page:
+			return nil, true
+		case previous.modTime.Equal(state.modTime) && previous.size == state.size:
+			continue
+		case !state.page:
+			return nil, true
+		}
+		pages = append(pages, path.Base(file))
+	}
+	return pages, len(pages) > 0
+}
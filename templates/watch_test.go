@@ -0,0 +1,77 @@
+package templates
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangedPages(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Second)
+	old := map[string]fileState{
+		"pages/home.tmpl":    {modTime: now, size: 10, page: true},
+		"pages/about.tmpl":   {modTime: now, size: 10, page: true},
+		"partials/card.tmpl": {modTime: now, size: 10},
+	}
+	with := func(file string, state fileState) map[string]fileState {
+		current := make(map[string]fileState)
+		for k, v := range old {
+			current[k] = v
+		}
+		current[file] = state
+		return current
+	}
+	without := func(file string) map[string]fileState {
+		current := with(file, fileState{})
+		delete(current, file)
+		return current
+	}
+
+	tests := []struct {
+		name    string
+		current map[string]fileState
+		pages   []string
+		changed bool
+	}{
+		{name: "unchanged", current: with("pages/home.tmpl", old["pages/home.tmpl"])},
+		{
+			name:    "page modified",
+			current: with("pages/home.tmpl", fileState{modTime: later, size: 10, page: true}),
+			pages:   []string{"home.tmpl"},
+			changed: true,
+		},
+		{
+			name:    "page resized",
+			current: with("pages/about.tmpl", fileState{modTime: now, size: 12, page: true}),
+			pages:   []string{"about.tmpl"},
+			changed: true,
+		},
+		{
+			name:    "partial modified",
+			current: with("partials/card.tmpl", fileState{modTime: later, size: 10}),
+			changed: true,
+		},
+		{
+			name:    "file added",
+			current: with("pages/new.tmpl", fileState{modTime: now, size: 1, page: true}),
+			changed: true,
+		},
+		{
+			name:    "file removed",
+			current: without("pages/about.tmpl"),
+			changed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, changed := changedPages(old, tt.current)
+			sort.Strings(pages)
+			if changed != tt.changed || strings.Join(pages, ",") != strings.Join(tt.pages, ",") {
+				t.Errorf("changedPages() = %q, %v, want %q, %v", pages, changed, tt.pages, tt.changed)
+			}
+		})
+	}
+}
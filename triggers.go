@@ -0,0 +1,199 @@
+package htmx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// TriggerPhase determines when the htmx client dispatches a triggered event.
+//   - https://htmx.org/headers/hx-trigger/
+type TriggerPhase int
+
+const (
+	// Events are triggered as soon as the response is received, via the
+	// "HX-Trigger" header.
+	PhaseReceived TriggerPhase = iota
+	// Events are triggered after the swap step, via the "HX-Trigger-After-Swap"
+	// header.
+	PhaseAfterSwap
+	// Events are triggered after the settle step, via the
+	// "HX-Trigger-After-Settle" header.
+	PhaseAfterSettle
+)
+
+func (p TriggerPhase) header() string {
+	switch p {
+	case PhaseAfterSwap:
+		return HeaderHXTriggerAfterSwap
+	case PhaseAfterSettle:
+		return HeaderHXTriggerAfterSettle
+	}
+	return HeaderHXTrigger
+}
+
+var triggerPhases = []TriggerPhase{PhaseReceived, PhaseAfterSwap, PhaseAfterSettle}
+
+type triggerEvent struct {
+	name   string
+	detail json.RawMessage
+}
+
+// Triggers collects client side events raised while handling a single request.
+// Any middleware, service or handler with access to the collector may add
+// events, with or without details, in any order; just before the response
+// header is written the events of each phase are merged into a single header.
+// When every event of a phase is detail-free, the header lists the event names;
+// otherwise it contains a JSON object keyed by event name. Adding an event with
+// the same name again replaces its detail. Triggers is safe for concurrent use.
+type Triggers struct {
+	mu     sync.Mutex
+	phases map[TriggerPhase][]triggerEvent
+}
+
+// NewTriggers creates an empty trigger event collector.
+func NewTriggers() *Triggers {
+	return &Triggers{phases: make(map[TriggerPhase][]triggerEvent)}
+}
+
+// Add collects the event(s) to be triggered client side during the phase.
+func (t *Triggers) Add(phase TriggerPhase, event TriggerEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, e := range event.triggerEvents() {
+		t.phases[phase] = mergeTriggerEvent(t.phases[phase], e)
+	}
+}
+
+func mergeTriggerEvent(events []triggerEvent, event triggerEvent) []triggerEvent {
+	for i := range events {
+		if events[i].name != event.name {
+			continue
+		} else if event.detail != nil {
+			events[i].detail = event.detail
+		}
+		return events
+	}
+	return append(events, event)
+}
+
+// Len returns the number of distinct events collected for the phase.
+func (t *Triggers) Len(phase TriggerPhase) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.phases[phase])
+}
+
+// HeaderValue returns the merged header value for the phase, or an empty
+// string if no events were collected.
+func (t *Triggers) HeaderValue(phase TriggerPhase) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return encodeTriggerEvents(t.phases[phase])
+}
+
+func (t *Triggers) writeHeaders(set func(key, value string)) {
+	for _, phase := range triggerPhases {
+		if value := t.HeaderValue(phase); value != "" {
+			set(phase.header(), value)
+		}
+	}
+}
+
+func encodeTriggerEvents(events []triggerEvent) string {
+	if len(events) == 0 {
+		return ""
+	}
+
+	detailed := false
+	names := make([]string, 0, len(events))
+	for _, event := range events {
+		names = append(names, event.name)
+		detailed = detailed || event.detail != nil
+	}
+
+	if !detailed {
+		return strings.Join(names, ",")
+	}
+
+	// encode manually so that events are triggered in the order they were added.
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, event := range events {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(event.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		if event.detail == nil {
+			buf.WriteString("{}")
+		} else {
+			buf.Write(event.detail)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+type triggersContextKey struct{}
+
+// ContextWithTriggers returns a copy of the parent context carrying the trigger
+// event collector, allowing code without access to the response writer to raise
+// client side events with Trigger(). The HTMX() handler wrapper attaches the
+// collector of each request automatically.
+func ContextWithTriggers(parent context.Context, triggers *Triggers) context.Context {
+	return context.WithValue(parent, triggersContextKey{}, triggers)
+}
+
+// TriggersFromContext returns the trigger event collector attached to the
+// context, if any. A nil context carries no collector.
+func TriggersFromContext(ctx context.Context) (*Triggers, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	triggers, ok := ctx.Value(triggersContextKey{}).(*Triggers)
+	return triggers, ok && triggers != nil
+}
+
+// Trigger raises the event(s) client side as soon as the response is received,
+// using the collector attached to the context. Service code far below the
+// handler can notify the client without access to the response writer:
+//
+//	func (s *SnippetService) Create(ctx context.Context, snippet *Snippet) error {
+//		// ...
+//		htmx.Trigger(ctx, htmx.TriggerEvents("snippetCreated"))
+//		return nil
+//	}
+//
+// If the context is nil or carries no collector, such as outside of a request,
+// the call does nothing.
+func Trigger(ctx context.Context, event TriggerEvent) {
+	TriggerAt(ctx, PhaseReceived, event)
+}
+
+// TriggerAfterSwap raises the event(s) client side after the swap step, using
+// the collector attached to the context. If the context carries no collector,
+// the call does nothing.
+func TriggerAfterSwap(ctx context.Context, event TriggerEvent) {
+	TriggerAt(ctx, PhaseAfterSwap, event)
+}
+
+// TriggerAfterSettle raises the event(s) client side after the settle step,
+// using the collector attached to the context. If the context carries no
+// collector, the call does nothing.
+func TriggerAfterSettle(ctx context.Context, event TriggerEvent) {
+	TriggerAt(ctx, PhaseAfterSettle, event)
+}
+
+// TriggerAt raises the event(s) client side during the phase, using the
+// collector attached to the context. If the context carries no collector, the
+// call does nothing.
+func TriggerAt(ctx context.Context, phase TriggerPhase, event TriggerEvent) {
+	if triggers, ok := TriggersFromContext(ctx); ok {
+		triggers.Add(phase, event)
+	}
+}
@@ -0,0 +1,45 @@
+package htmx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTriggerContext(t *testing.T) {
+	var nilContext context.Context
+	collector := NewTriggers()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{name: "nil context", ctx: nilContext},
+		{name: "context without collector", ctx: context.Background()},
+		{name: "context with nil collector", ctx: ContextWithTriggers(context.Background(), nil)},
+		{name: "context with collector", ctx: ContextWithTriggers(context.Background(), collector), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			triggers, ok := TriggersFromContext(tt.ctx)
+			if ok != tt.want || (triggers != nil) != tt.want {
+				t.Errorf("TriggersFromContext() = %v, %v, want %v", triggers, ok, tt.want)
+			}
+			// raising events must never panic.
+			Trigger(tt.ctx, TriggerEvents("received"))
+			TriggerAfterSwap(tt.ctx, TriggerEvents("swapped"))
+			TriggerAt(tt.ctx, PhaseAfterSettle, TriggerEvents("settled"))
+		})
+	}
+
+	want := map[TriggerPhase]string{
+		PhaseReceived:    "received",
+		PhaseAfterSwap:   "swapped",
+		PhaseAfterSettle: "settled",
+	}
+	for phase, value := range want {
+		if got := collector.HeaderValue(phase); got != value {
+			t.Errorf("HeaderValue(%v) = %q, want %q", phase, got, value)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package htmx
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldErrors maps the names of invalid form fields to a description of the
+// problem, suitable for display next to the field:
+//
+//	{{with .Errors.title}}<label class="error">{{.}}</label>{{end}}
+type FieldErrors map[string]string
+
+// Error lists the field errors, ordered by field name.
+func (e FieldErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make([]string, 0, len(names))
+	for _, name := range names {
+		problems = append(problems, name+": "+e[name])
+	}
+	return "htmx: invalid form: " + strings.Join(problems, "; ")
+}
+
+// add records the problem, unless the field already has one.
+func (e FieldErrors) add(name, problem string) {
+	if _, ok := e[name]; !ok {
+		e[name] = problem
+	}
+}
+
+// Validate checks the fields of the struct, or pointer to a struct, against
+// the rules of their "validate" tag, returning the errors keyed by the form
+// name of each invalid field; see DecodeValues(). Nil is returned if every
+// field is valid. Rules are separated by commas:
+//
+//	type SignUp struct {
+//		Email   string   `form:"email" validate:"required,email"`
+//		Name    string   `form:"name" validate:"required,min=2,max=50"`
+//		Age     int      `form:"age" validate:"min=18" message:"You must be an adult"`
+//		Plan    string   `form:"plan" validate:"oneof=free pro"`
+//		Website string   `form:"website" validate:"url"`
+//		Tags    []string `form:"tags" validate:"max=5"`
+//	}
+//
+// The supported rules are:
+//   - required: the value is not empty, or blank
+//   - min=n, max=n: bounds the value of numbers, the number of characters of
+//     strings, and the length of slices
+//   - email: the value is an email address
+//   - url: the value is an absolute http or https url
+//   - oneof=a b c: the value is one of the space separated values
+//
+// Empty values are only checked by the "required" rule: blank strings, empty
+// slices and nil pointers. Numbers and booleans are never empty, as their zero
+// value may be a valid answer; use a pointer to require them, or decode the
+// form with DecodeForm(), which treats the fields missing from the form as
+// empty. The "message" tag replaces the description of any problem with the
+// field. Nested structs, and slices of structs, are validated as well, keyed
+// by their position. Validate panics if a tag contains an unknown or malformed
+// rule.
+func Validate(v any) FieldErrors {
+	return validateForm(v, nil, nil)
+}
+
+// ValidateField checks the field of the struct with the form name against the
+// rules of its "validate" tag, in the same way as Validate(), returning the
+// problem with the field, or an empty string if the field is valid or does not
+// exist. Nested fields are named by their path, such as "address.street" or
+// "contacts[0].street".
+func ValidateField(v any, name string) string {
+	return validateForm(v, func(field string) bool { return field == name }, nil)[name]
+}
+
+// validateForm validates the fields of v whose name is accepted by include, or
+// every field if include is nil. Form records what the form gave the fields,
+// if v was decoded from one.
+func validateForm(v any, include func(name string) bool, form *decodedForm) FieldErrors {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	val := validator{include: include, form: form, errs: FieldErrors{}}
+	val.validateStruct(rv, "")
+	if len(val.errs) == 0 {
+		return nil
+	}
+	return val.errs
+}
+
+type validator struct {
+	include func(name string) bool
+	form    *decodedForm
+	errs    FieldErrors
+}
+
+func (val *validator) validateStruct(v reflect.Value, prefix string) {
+	formFields(v, prefix, func(field reflect.Value, sf reflect.StructField, key string) error {
+		if rules := sf.Tag.Get("validate"); rules != "" && (val.include == nil || val.include(key)) {
+			if problem := val.validateField(field, rules); problem != "" {
+				if message := sf.Tag.Get("message"); message != "" {
+					problem = message
+				}
+				val.errs.add(key, problem)
+			}
+		}
+		val.validateNested(field, key)
+		return nil
+	})
+}
+
+// validateNested validates the structs within the field.
+func (val *validator) validateNested(v reflect.Value, key string) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if isScalar(v.Type()) {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		val.validateStruct(v, key+".")
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			val.validateNested(v.Index(i), val.elementName(v.Index(i), key, i))
+		}
+	}
+}
+
+// elementName returns the name of the slice element at position i: the name
+// it was decoded from, or its position if it was not decoded from a form.
+func (val *validator) elementName(elem reflect.Value, key string, i int) string {
+	if val.form != nil {
+		if k, ok := fieldKeyOf(elem); ok {
+			if name, ok := val.form.names[k]; ok {
+				return name
+			}
+		}
+	}
+	return fmt.Sprintf("%s[%d]", key, i)
+}
+
+// validateField returns the problem with the value, or an empty string if
+// the value follows every rule.
+func (val *validator) validateField(v reflect.Value, rules string) string {
+	empty := false
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+			empty = true
+			break
+		}
+		v = v.Elem()
+	}
+	if !empty {
+		empty = val.isEmpty(v)
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			if empty {
+				return "This field is required"
+			}
+			continue
+		} else if empty {
+			continue
+		}
+
+		if problem := checkRule(v, name, arg); problem != "" {
+			return problem
+		}
+	}
+	return ""
+}
+
+// isEmpty reports whether the value is missing: a blank string or an empty
+// slice, or a field the form gave no value when decoded from one.
+func (val *validator) isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	if !isScalar(v.Type()) {
+		return v.IsZero()
+	}
+	if val.form != nil {
+		key, ok := fieldKeyOf(v)
+		return ok && !val.form.filled[key]
+	}
+	return v.Type() == timeType && v.IsZero()
+}
+
+func checkRule(v reflect.Value, name, arg string) string {
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("htmx: invalid validation rule %s=%q", name, arg))
+		}
+		size, format := ruleSize(v, name)
+		if name == "min" && size < limit {
+			return fmt.Sprintf(format, "at least", arg)
+		} else if name == "max" && size > limit {
+			return fmt.Sprintf(format, "at most", arg)
+		}
+	case "email":
+		s := ruleString(v, name)
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return "Must be a valid email address"
+		}
+	case "url":
+		u, err := url.Parse(ruleString(v, name))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "Must be a valid URL"
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		value := fmt.Sprint(v.Interface())
+		for _, option := range options {
+			if value == option {
+				return ""
+			}
+		}
+		return "Must be one of: " + strings.Join(options, ", ")
+	default:
+		panic(fmt.Sprintf("htmx: unknown validation rule %q", name))
+	}
+	return ""
+}
+
+// ruleSize returns the size of the value compared by the min and max rules,
+// along with the format of the problem description.
+func ruleSize(v reflect.Value, rule string) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "Must be %s %s characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), "Must have %s %s items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "Must be %s %s"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "Must be %s %s"
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "Must be %s %s"
+	}
+	panic(fmt.Sprintf("htmx: validation rule %q does not apply to %s", rule, v.Type()))
+}
+
+func ruleString(v reflect.Value, rule string) string {
+	if v.Kind() != reflect.String {
+		panic(fmt.Sprintf("htmx: validation rule %q does not apply to %s", rule, v.Type()))
+	}
+	return v.String()
+}
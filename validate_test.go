@@ -0,0 +1,225 @@
+package htmx
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type signUpForm struct {
+	Name     string `form:"name" validate:"required,min=2"`
+	Age      int    `form:"age" validate:"min=18"`
+	Children int    `form:"children" validate:"required,min=0"`
+	Rating   *int   `form:"rating" validate:"required"`
+	Contacts []struct {
+		Phone int `form:"phone" validate:"required"`
+	} `form:"contacts"`
+}
+
+func TestDecodeFormEmptiness(t *testing.T) {
+	tests := []struct {
+		name   string
+		values url.Values
+		want   FieldErrors
+	}{
+		{
+			name:   "every field given",
+			values: url.Values{"name": {"Ann"}, "age": {"30"}, "children": {"2"}, "rating": {"5"}},
+		},
+		{
+			name:   "zero given to a min rule",
+			values: url.Values{"name": {"Ann"}, "age": {"0"}, "children": {"2"}, "rating": {"5"}},
+			want:   FieldErrors{"age": "Must be at least 18"},
+		},
+		{
+			name:   "zero given to a required rule",
+			values: url.Values{"name": {"Ann"}, "children": {"0"}, "rating": {"0"}},
+		},
+		{
+			name:   "missing numbers",
+			values: url.Values{"name": {"Ann"}},
+			want:   FieldErrors{"children": "This field is required", "rating": "This field is required"},
+		},
+		{
+			name:   "blank values",
+			values: url.Values{"name": {"  "}, "age": {""}, "children": {" "}, "rating": {""}},
+			want: FieldErrors{
+				"name":     "This field is required",
+				"children": "This field is required",
+				"rating":   "This field is required",
+			},
+		},
+		{
+			name: "nested fields",
+			values: url.Values{
+				"name": {"Ann"}, "children": {"1"}, "rating": {"1"},
+				"contacts[0].phone": {"0"}, "contacts[3].name": {"Bob"},
+			},
+			want: FieldErrors{"contacts[3].phone": "This field is required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.values.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			var form signUpForm
+			err := NewRequest(r).DecodeForm(&form)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("DecodeForm() = %v, want nil", err)
+				}
+				return
+			}
+			assertFieldErrors(t, err, tt.want)
+		})
+	}
+}
+
+func TestValidateEmptiness(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name string
+		form signUpForm
+		want FieldErrors
+	}{
+		{
+			name: "zero given to a min rule",
+			form: signUpForm{Name: "Ann", Rating: &zero},
+			want: FieldErrors{"age": "Must be at least 18"},
+		},
+		{
+			name: "zero given to a required rule",
+			form: signUpForm{Name: "Ann", Age: 18, Rating: &zero},
+		},
+		{
+			name: "nil pointer",
+			form: signUpForm{Name: "Ann", Age: 18},
+			want: FieldErrors{"rating": "This field is required"},
+		},
+		{
+			name: "blank string",
+			form: signUpForm{Name: " ", Age: 18, Rating: &zero},
+			want: FieldErrors{"name": "This field is required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Validate(&tt.form)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("Validate() = %v, want nil", got)
+				}
+				return
+			}
+			assertFieldErrors(t, got, tt.want)
+		})
+	}
+}
+
+func TestDecodeValuesThenValidate(t *testing.T) {
+	var form signUpForm
+	if err := DecodeValues(url.Values{"name": {"Ann"}, "age": {"0"}, "children": {"0"}, "rating": {"1"}}, &form); err != nil {
+		t.Fatalf("DecodeValues() = %v", err)
+	}
+	assertFieldErrors(t, Validate(&form), FieldErrors{"age": "Must be at least 18"})
+}
+
+func assertFieldErrors(t *testing.T, err error, want FieldErrors) {
+	t.Helper()
+	got, ok := err.(FieldErrors)
+	if !ok {
+		t.Fatalf("error = %v, want FieldErrors", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("errors = %v, want %v", got, want)
+	}
+	for name, problem := range want {
+		if got[name] != problem {
+			t.Errorf("errors[%q] = %q, want %q", name, got[name], problem)
+		}
+	}
+}
+
+func TestFieldValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger string
+		body    string
+		status  int
+		want    string
+	}{
+		{
+			name:    "valid field",
+			trigger: "name",
+			body:    "name=Ann&age=3",
+			status:  http.StatusOK,
+			want:    "name: ",
+		},
+		{
+			name:    "invalid field",
+			trigger: "age",
+			body:    "name=A&age=3",
+			status:  http.StatusOK,
+			want:    "age: Must be at least 18",
+		},
+		{
+			name:    "sparse index",
+			trigger: "contacts[3].phone",
+			body:    "contacts[0].phone=1&contacts[3].phone=",
+			status:  http.StatusOK,
+			want:    "contacts[3].phone: This field is required",
+		},
+		{
+			name:    "sparse index given a value",
+			trigger: "contacts[3].phone",
+			body:    "contacts[0].phone=&contacts[3].phone=5",
+			status:  http.StatusOK,
+			want:    "contacts[3].phone: ",
+		},
+		{
+			name:   "missing trigger name",
+			body:   "name=Ann",
+			status: http.StatusBadRequest,
+			want:   "missing HX-Trigger-Name header\n",
+		},
+		{
+			name:    "malformed form",
+			trigger: "name",
+			body:    "name=%zz",
+			status:  http.StatusBadRequest,
+			want:    "Bad Request\n",
+		},
+	}
+
+	handler := HTMX(FieldValidator{
+		Form: func() any { return &signUpForm{} },
+		Render: func(r *Request, form any, name, problem string) Component {
+			return ComponentFunc(func(w io.Writer) error {
+				_, err := io.WriteString(w, name+": "+problem)
+				return err
+			})
+		},
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set(HeaderHXRequest, "true")
+			if tt.trigger != "" {
+				r.Header.Set(HeaderHXTriggerName, tt.trigger)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != tt.status || rec.Body.String() != tt.want {
+				t.Errorf("response = %d %q, want %d %q", rec.Code, rec.Body.String(), tt.status, tt.want)
+			}
+		})
+	}
+}
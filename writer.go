@@ -1,73 +1,431 @@
 package htmx
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 // ResponseWriter is responsible for write htmx compliant http responses
-// back to the client.
+// back to the client. It records the status code and number of bytes written
+// for inspection by middleware.
+//
+// ResponseWriter always implements the optional http.Flusher, http.Hijacker
+// and io.ReaderFrom interfaces, deferring to the underlying writer, since
+// handlers receive it as a concrete type. Unlike most wrappers, a successful
+// type assertion therefore does not mean the underlying writer supports the
+// interface: Flush does nothing and Hijack returns an error wrapping
+// http.ErrNotSupported if it does not. Use http.NewResponseController(), or
+// check the errors of FlushError and Hijack, to find out.
 type ResponseWriter struct {
 	http.ResponseWriter
+	state *writerState
+}
+
+// writerState is shared between copies of a ResponseWriter.
+type writerState struct {
+	triggers     *Triggers
+	wroteHeader  bool
+	request      *Request
+	errorHandler ErrorHandler
+	status       int
+	written      int64
+	hijacked     bool
 }
 
 // NewResponseWriter creates a new htmx response writer instance,
 // using the underlying http response writer to generate a http response.
 func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
-	return &ResponseWriter{ResponseWriter: w}
+	return &ResponseWriter{
+		ResponseWriter: w,
+		state:          &writerState{triggers: NewTriggers()},
+	}
+}
+
+// WriteHeader writes any collected trigger events into the response headers
+// before sending the http response header with the provided status code.
+// Informational (1xx) headers are sent as is, leaving the trigger events to the
+// final header.
+func (r ResponseWriter) WriteHeader(statusCode int) {
+	if statusCode >= 200 {
+		r.writeTriggers()
+		if r.state != nil && r.state.status == 0 {
+			r.state.status = statusCode
+		}
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write writes any collected trigger events into the response headers
+// before writing the data to the http response.
+func (r ResponseWriter) Write(b []byte) (int, error) {
+	r.writeTriggers()
+	n, err := r.ResponseWriter.Write(b)
+	r.recordWrite(int64(n))
+	return n, err
+}
+
+// ReadFrom writes any collected trigger events into the response headers
+// before copying the data from src to the http response. It implements
+// io.ReaderFrom, allowing the underlying writer to optimize the copy, such
+// as by using sendfile.
+func (r ResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	r.writeTriggers()
+	var (
+		n   int64
+		err error
+	)
+	if rf, ok := r.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		// hide any ReadFrom method of the writer to prevent recursion.
+		n, err = io.Copy(struct{ io.Writer }{r.ResponseWriter}, src)
+	}
+	r.recordWrite(n)
+	return n, err
+}
+
+func (r ResponseWriter) recordWrite(n int64) {
+	if r.state == nil {
+		return
+	}
+	if r.state.status == 0 {
+		r.state.status = http.StatusOK
+	}
+	r.state.written += n
+}
+
+// Flush writes any collected trigger events into the response headers, then
+// sends any buffered data to the client. Flush implements http.Flusher, allowing
+// responses such as server sent event streams to be written through the htmx
+// response writer; it does nothing if the underlying writer cannot flush.
+func (r ResponseWriter) Flush() {
+	r.FlushError()
+}
+
+// FlushError is like Flush, but returns an error wrapping http.ErrNotSupported
+// if the underlying writer cannot flush. It is used by http.ResponseController.
+func (r ResponseWriter) FlushError() error {
+	r.writeTriggers()
+	if r.state != nil && r.state.status == 0 {
+		r.state.status = http.StatusOK
+	}
+	return http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Hijack lets the caller take over the connection, as required by protocols
+// such as WebSockets. It implements http.Hijacker, returning an error
+// wrapping http.ErrNotSupported if the underlying writer cannot be hijacked.
+// Once hijacked, Status() reports 101 Switching Protocols unless a status was
+// written before, and bytes written to the connection are not counted.
+func (r ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && r.state != nil {
+		// the response is now written by the caller.
+		r.state.wroteHeader = true
+		r.state.hijacked = true
+		if r.state.status == 0 {
+			r.state.status = http.StatusSwitchingProtocols
+		}
+	}
+	return conn, brw, err
+}
+
+// Hijacked returns true if the connection was taken over with Hijack().
+func (r ResponseWriter) Hijacked() bool {
+	return r.state != nil && r.state.hijacked
+}
+
+// Unwrap returns the underlying http response writer, allowing
+// http.ResponseController to reach it.
+func (r ResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Status returns the status code of the response, or zero if the response
+// header has not been written yet. Hijacked connections report 101 Switching
+// Protocols.
+func (r ResponseWriter) Status() int {
+	if r.state == nil {
+		return 0
+	}
+	return r.state.status
+}
+
+// BytesWritten returns the number of bytes of the response body written so far.
+func (r ResponseWriter) BytesWritten() int64 {
+	if r.state == nil {
+		return 0
+	}
+	return r.state.written
+}
+
+// Triggers returns the collector of client side events raised while handling
+// the current request. Events added before the response header is written are
+// merged into the "HX-Trigger" family of headers. Writers not created with
+// NewResponseWriter() have no collector, and nil is returned.
+func (r ResponseWriter) Triggers() *Triggers {
+	if r.state == nil {
+		return nil
+	}
+	return r.state.triggers
+}
+
+// writeTriggers encodes the collected trigger events into the response
+// headers, once, just before the response header is written.
+func (r ResponseWriter) writeTriggers() {
+	if r.state == nil || r.state.wroteHeader {
+		return
+	}
+	r.state.wroteHeader = true
+	r.state.triggers.writeHeaders(r.setHeader)
+}
+
+// addTrigger adds the event to the collector, or writes it to the header
+// directly if the writer has no collector.
+func (r ResponseWriter) addTrigger(phase TriggerPhase, event TriggerEvent) {
+	if triggers := r.Triggers(); triggers != nil {
+		triggers.Add(phase, event)
+		return
+	}
+	triggers := NewTriggers()
+	triggers.Add(phase, event)
+	triggers.writeHeaders(r.setHeader)
 }
 
 // SetPushHeader sets the "HX-Push" header which triggers the web client to
-// push the target URL into the browser's address bar.
-func (r ResponseWriter) SetPushHeader(url url.URL) {
-	r.Header().Set(HeaderHXPushURL, url.String())
+// push the target URL into the browser's address bar. An error is returned, and
+// the header is left unchanged, if the URL is refused by the redirect policy;
+// see WithRedirectPolicy().
+func (r ResponseWriter) SetPushHeader(url url.URL) error {
+	return r.setURLHeader(HeaderHXPushURL, url)
+}
+
+// SetNoPushHeader sets the "HX-Push-Url" header to "false", which prevents the
+// web client from pushing a new URL into the browser history, overriding any
+// "hx-push-url" attribute on the requesting element.
+func (r ResponseWriter) SetNoPushHeader() {
+	r.setHeader(HeaderHXPushURL, "false")
+}
+
+// SetReplaceURLHeader sets the "HX-Replace-Url" header which triggers the web
+// client to replace the current URL in the browser's address bar, without
+// creating a new history entry. An error is returned, and the header is left
+// unchanged, if the URL is refused by the redirect policy.
+func (r ResponseWriter) SetReplaceURLHeader(url url.URL) error {
+	return r.setURLHeader(HeaderHXReplaceURL, url)
+}
+
+// SetNoReplaceURLHeader sets the "HX-Replace-Url" header to "false", which
+// prevents the web client from updating the current URL, overriding any
+// "hx-replace-url" attribute on the requesting element.
+func (r ResponseWriter) SetNoReplaceURLHeader() {
+	r.setHeader(HeaderHXReplaceURL, "false")
+}
+
+// SetRetargetHeader sets the "HX-Retarget" header, a CSS selector that updates
+// the target of the content update to a different element on the page. An error
+// is returned, and the header is left unchanged, if the selector is malformed.
+func (r ResponseWriter) SetRetargetHeader(selector string) error {
+	if err := validateSelector(selector); err != nil {
+		return err
+	}
+	r.setHeader(HeaderHXRetarget, selector)
+	return nil
+}
+
+// SetReselectHeader sets the "HX-Reselect" header, a CSS selector that chooses
+// which part of the response is swapped in. An error is returned, and the header
+// is left unchanged, if the selector is malformed.
+func (r ResponseWriter) SetReselectHeader(selector string) error {
+	if err := validateSelector(selector); err != nil {
+		return err
+	}
+	r.setHeader(HeaderHXReselect, selector)
+	return nil
 }
 
 // SetRedirectHeader sets the "HX-Redirect" header which triggers the web client
-// to redirect to a new URL.
-func (r ResponseWriter) SetRedirectHeader(url url.URL) {
-	r.Header().Set(HeaderHXRedirect, url.String())
+// to redirect to a new URL. An error is returned, and the header is left
+// unchanged, if the URL is refused by the redirect policy.
+func (r ResponseWriter) SetRedirectHeader(url url.URL) error {
+	return r.setURLHeader(HeaderHXRedirect, url)
 }
 
 // SetLocationHeader sets the "HX-Location" header which triggers the web client
-// to redirect to a new URL that acts as a swap.
-func (r ResponseWriter) SetLocationHeader(url url.URL) {
-	r.Header().Set(HeaderHXLocation, url.String())
+// to redirect to a new URL that acts as a swap. To provide additional context
+// for the swap, such as the target element, use SetLocationOptionsHeader().
+// An error is returned, and the header is left unchanged, if the URL is refused
+// by the redirect policy.
+func (r ResponseWriter) SetLocationHeader(url url.URL) error {
+	return r.setURLHeader(HeaderHXLocation, url)
+}
+
+// setURLHeader sets the header to the url, once checked against the redirect
+// policy.
+func (r ResponseWriter) setURLHeader(key string, url url.URL) error {
+	target, err := r.checkRedirect(url.String())
+	if err != nil {
+		return err
+	}
+	r.setHeader(key, target)
+	return nil
+}
+
+// SetReswapHeader sets the "HX-Reswap" header which overrides how the response
+// will be swapped by the web client. An error is returned, and the header is left
+// unchanged, if the swap specification is invalid.
+func (r ResponseWriter) SetReswapHeader(swap Swap) error {
+	if err := swap.Validate(); err != nil {
+		return err
+	}
+	r.setHeader(HeaderHXReswap, swap.String())
+	return nil
+}
+
+// SetLocationOptionsHeader sets the "HX-Location" header which triggers the web
+// client to issue a request to the location path and swap the response, as if
+// an element with "hx-get" had been clicked. When only the path is provided, the
+// plain path form of the header is used; otherwise the location is encoded as a
+// JSON object. An error is returned, and the header is left unchanged, if the
+// location is invalid, cannot be encoded, or its path is refused by the redirect
+// policy.
+func (r ResponseWriter) SetLocationOptionsHeader(location Location) error {
+	path, err := r.checkRedirect(location.Path)
+	if err != nil {
+		return err
+	}
+	location.Path = path
+	value, err := location.headerValue()
+	if err != nil {
+		return err
+	}
+	r.setHeader(HeaderHXLocation, value)
+	return nil
 }
 
 // SetRefreshHeader sets the "HX-Refresh" header which triggers a full refresh
 // of the web client.
 func (r ResponseWriter) SetRefreshHeader() {
-	r.Header().Set(HeaderHXRefresh, "true")
+	r.setHeader(HeaderHXRefresh, "true")
 }
 
 // TriggerEvent defines a single event, or multiple events that should be
 // triggered client side once a htmx response is received.
 type TriggerEvent interface {
-	triggerHeaderValue() string
+	triggerEvents() []triggerEvent
 }
 
-// SetTriggerHeader writes the "HX-Trigger-After-Swap" header
-// to the http response, triggering client side event(s) upon receipt.
+// SetTriggerHeader adds the event(s) to the "HX-Trigger" header
+// of the http response, triggering client side event(s) upon receipt.
+// Events from repeated calls are merged rather than overwritten.
 func (r ResponseWriter) SetTriggerHeader(event TriggerEvent) {
-	r.Header().Set(HeaderHXTrigger, event.triggerHeaderValue())
+	r.addTrigger(PhaseReceived, event)
 }
 
-// SetTriggerAfterSettleHeader writes the "HX-Trigger-After-Swap" header
-// to the http response, triggering client side event(s) after the settling step.
+// SetTriggerAfterSettleHeader adds the event(s) to the "HX-Trigger-After-Settle"
+// header of the http response, triggering client side event(s) after the settling step.
+// Events from repeated calls are merged rather than overwritten.
 func (r ResponseWriter) SetTriggerAfterSettleHeader(event TriggerEvent) {
-	r.Header().Set(HeaderHXTriggerAfterSettle, event.triggerHeaderValue())
+	r.addTrigger(PhaseAfterSettle, event)
 }
 
-// SetTriggerAfterSwapHeader writes the "HX-Trigger-After-Swap" header
-// to the http response, triggering client side event(s) after the swap step.
+// SetTriggerAfterSwapHeader adds the event(s) to the "HX-Trigger-After-Swap" header
+// of the http response, triggering client side event(s) after the swap step.
+// Events from repeated calls are merged rather than overwritten.
 func (r ResponseWriter) SetTriggerAfterSwapHeader(event TriggerEvent) {
-	r.Header().Set(HeaderHXTriggerAfterSwap, event.triggerHeaderValue())
+	r.addTrigger(PhaseAfterSwap, event)
+}
+
+// setHeader sets the response header after sanitizing the value. Header values
+// frequently include user controlled urls and selectors; stripping control
+// characters ensures they can never split the response.
+func (r ResponseWriter) setHeader(key, value string) {
+	r.Header().Set(key, sanitizeHeaderValue(value))
+}
+
+func sanitizeHeaderValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// ErrInvalidSelector is returned when a CSS selector is clearly malformed.
+var ErrInvalidSelector = errors.New("htmx: invalid selector")
+
+// validateSelector performs basic sanity checks on a CSS selector: it must not
+// be blank, must not contain control characters, and must have balanced quotes,
+// brackets and parentheses. Extended htmx selectors such as "closest tr" are
+// accepted. The returned error wraps ErrInvalidSelector.
+func validateSelector(selector string) error {
+	if problem := selectorProblem(selector); problem != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidSelector, problem)
+	}
+	return nil
+}
+
+// selectorProblem describes why the selector is malformed, or returns an empty
+// string if it passes all checks.
+func selectorProblem(selector string) string {
+	if strings.TrimSpace(selector) == "" {
+		return "empty selector"
+	}
+
+	var (
+		stack   []rune
+		quote   rune
+		escaped bool
+	)
+
+	for _, c := range selector {
+		switch {
+		case unicode.IsControl(c):
+			return fmt.Sprintf("%q contains control characters", selector)
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[':
+			stack = append(stack, c)
+		case c == ')' || c == ']':
+			open := '('
+			if c == ']' {
+				open = '['
+			}
+			if len(stack) == 0 || stack[len(stack)-1] != open {
+				return fmt.Sprintf("%q has unbalanced %q", selector, c)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	if quote != 0 {
+		return fmt.Sprintf("%q has an unterminated string", selector)
+	} else if len(stack) > 0 {
+		return fmt.Sprintf("%q has unbalanced %q", selector, stack[len(stack)-1])
+	}
+	return ""
 }
 
 type Component interface {
@@ -81,16 +439,29 @@ func (f ComponentFunc) RenderHTMX(w io.Writer) error {
 }
 
 // WriteComponent invokes the Render() method on the provided component,
-// writing the contents to the http response writer.
+// writing the contents to the http response writer. If the component fails
+// to render, the error is passed to the ErrorHandler configured for the
+// handler via WithErrorHandler(), or DefaultErrorHandler otherwise.
+//
+// Components implementing Versioned, or wrapped with WithETag(), opt into
+// conditional responses: the "ETag" header is set, and the content is omitted
+// if the client already has the current version.
 func WriteComponent(w http.ResponseWriter, component Component, status int) {
 	// initialize new buffer; a temporary buffer is used to ensure
 	// the template transformation is valid and safe to transport back
 	// to the client.
 	buf := bytes.Buffer{}
 
-	err := component.RenderHTMX(&buf)
+	written, content, err := writeConditional(w, component, status)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		handleRenderError(w, err)
+		return
+	} else if written {
+		return
+	} else if content != nil {
+		buf.Write(content)
+	} else if err = component.RenderHTMX(&buf); err != nil {
+		handleRenderError(w, err)
 		return
 	}
 
@@ -100,9 +471,9 @@ func WriteComponent(w http.ResponseWriter, component Component, status int) {
 
 // TriggerEvents creates an event trigger for either a single event or multiple
 // events, without providing any additional details. To provide JSON context
-// with events, use the TriggerEventsWithDetails() function.
+// with events, use the TriggerEventsWithContext() function.
 func TriggerEvents(eventName string, eventNames ...string) triggerEvents {
-	eventNames = append(eventNames, eventName)
+	eventNames = append([]string{eventName}, eventNames...)
 	return triggerEvents{eventNames: eventNames}
 }
 
@@ -136,28 +507,406 @@ func TriggerEvents(eventName string, eventNames ...string) triggerEvents {
 //		},
 //	))
 //
-// If the provided context cannot be encoded into JSON, this function panics.
+// Events are triggered in the order of their names. If the provided context
+// cannot be encoded into JSON, this function panics.
 func TriggerEventsWithContext(context map[string]any) triggerEventsJSON {
-	return triggerEventsJSON{eventContext: context}
+	names := make([]string, 0, len(context))
+	for name := range context {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	events := make([]triggerEvent, 0, len(names))
+	for _, name := range names {
+		events = append(events, triggerEvent{name: name, detail: mustMarshalDetail(context[name])})
+	}
+	return triggerEventsJSON{events: events}
+}
+
+// TriggerEventWithTarget creates an event trigger for a single event that is
+// dispatched on the element matching the target CSS selector, rather than the
+// element that made the request. The detail is sent along with the event; when
+// it does not encode into a JSON object, it is available to the client as
+// "event.detail.value". If the detail cannot be encoded into JSON, this
+// function panics.
+func TriggerEventWithTarget(eventName, target string, detail any) triggerEventsJSON {
+	fields := map[string]json.RawMessage{}
+	if detail != nil {
+		data := mustMarshalDetail(detail)
+		if err := json.Unmarshal(data, &fields); err != nil {
+			fields = map[string]json.RawMessage{"value": data}
+		}
+	}
+	fields["target"] = mustMarshalDetail(target)
+	event := triggerEvent{name: eventName, detail: mustMarshalDetail(fields)}
+	return triggerEventsJSON{events: []triggerEvent{event}}
+}
+
+func mustMarshalDetail(detail any) json.RawMessage {
+	data, err := json.Marshal(detail)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal trigger message event: %s", err)
+		panic(err)
+	}
+	return data
 }
 
 type triggerEvents struct {
 	eventNames []string
 }
 
-func (s triggerEvents) triggerHeaderValue() string {
-	return strings.Join(s.eventNames, ",")
+func (s triggerEvents) triggerEvents() []triggerEvent {
+	events := make([]triggerEvent, 0, len(s.eventNames))
+	for _, name := range s.eventNames {
+		events = append(events, triggerEvent{name: name})
+	}
+	return events
 }
 
 type triggerEventsJSON struct {
-	eventContext map[string]any
+	events []triggerEvent
+}
+
+func (m triggerEventsJSON) triggerEvents() []triggerEvent {
+	return m.events
+}
+
+// SwapStyle describes how htmx swaps response content relative to the
+// target element.
+//   - https://htmx.org/attributes/hx-swap/
+type SwapStyle string
+
+const (
+	// Replace the inner html of the target element.
+	SwapInnerHTML SwapStyle = "innerHTML"
+	// Replace the entire target element with the response.
+	SwapOuterHTML SwapStyle = "outerHTML"
+	// Insert the response before the target element.
+	SwapBeforeBegin SwapStyle = "beforebegin"
+	// Insert the response before the first child of the target element.
+	SwapAfterBegin SwapStyle = "afterbegin"
+	// Insert the response after the last child of the target element.
+	SwapBeforeEnd SwapStyle = "beforeend"
+	// Insert the response after the target element.
+	SwapAfterEnd SwapStyle = "afterend"
+	// Deletes the target element regardless of the response.
+	SwapDelete SwapStyle = "delete"
+	// Does not append content from response (out of band items will still be processed).
+	SwapNone SwapStyle = "none"
+)
+
+func (s SwapStyle) valid() bool {
+	switch s {
+	case SwapInnerHTML, SwapOuterHTML, SwapBeforeBegin, SwapAfterBegin,
+		SwapBeforeEnd, SwapAfterEnd, SwapDelete, SwapNone:
+		return true
+	}
+	return false
+}
+
+// ScrollPosition is the edge of an element used by the "scroll" and "show"
+// swap modifiers.
+type ScrollPosition string
+
+const (
+	ScrollTop    ScrollPosition = "top"
+	ScrollBottom ScrollPosition = "bottom"
+)
+
+// ErrInvalidSwap is returned when a swap specification cannot be understood
+// by the htmx client.
+var ErrInvalidSwap = errors.New("htmx: invalid swap specification")
+
+// Swap is a typed htmx swap specification, used as the value of the
+// "HX-Reswap" response header or the "hx-swap" attribute. Swap values are
+// immutable; each modifier method returns an updated copy, so a base
+// specification may be shared and extended safely:
+//
+//	swap := htmx.NewSwap(htmx.SwapOuterHTML).
+//		SwapDelay(time.Second).
+//		SettleDelay(200 * time.Millisecond).
+//		Scroll(htmx.ScrollTop).
+//		ShowTarget("#el", htmx.ScrollBottom).
+//		FocusScroll(true).
+//		Transition(true)
+//
+// renders as "outerHTML swap:1s settle:200ms scroll:top show:#el:bottom
+// focus-scroll:true transition:true".
+//   - https://htmx.org/attributes/hx-swap/
+type Swap struct {
+	style       SwapStyle
+	swapDelay   *time.Duration
+	settleDelay *time.Duration
+	ignoreTitle *bool
+	scroll      *swapScroll
+	show        *swapScroll
+	focusScroll *bool
+	transition  *bool
+}
+
+type swapScroll struct {
+	selector string
+	position ScrollPosition
+	none     bool
+}
+
+// NewSwap creates a swap specification with the provided style and no modifiers.
+func NewSwap(style SwapStyle) Swap {
+	return Swap{style: style}
+}
+
+// Style returns the swap style of the specification.
+func (s Swap) Style() SwapStyle {
+	return s.style
+}
+
+// SwapDelay sets the "swap" modifier, the amount of time htmx waits after
+// receiving a response before swapping the content.
+func (s Swap) SwapDelay(d time.Duration) Swap {
+	s.swapDelay = &d
+	return s
+}
+
+// SettleDelay sets the "settle" modifier, the amount of time htmx waits
+// between the swap and settle steps.
+func (s Swap) SettleDelay(d time.Duration) Swap {
+	s.settleDelay = &d
+	return s
+}
+
+// IgnoreTitle sets the "ignoreTitle" modifier. When true, htmx does not update
+// the document title from a <title> tag found in the response.
+func (s Swap) IgnoreTitle(ignore bool) Swap {
+	s.ignoreTitle = &ignore
+	return s
+}
+
+// Scroll sets the "scroll" modifier, scrolling the target element to the
+// provided position after the swap.
+func (s Swap) Scroll(position ScrollPosition) Swap {
+	s.scroll = &swapScroll{position: position}
+	return s
+}
+
+// ScrollTarget sets the "scroll" modifier, scrolling the element matching the
+// CSS selector to the provided position after the swap. The selector
+// "window" scrolls the browser window.
+func (s Swap) ScrollTarget(selector string, position ScrollPosition) Swap {
+	s.scroll = &swapScroll{selector: selector, position: position}
+	return s
+}
+
+// Show sets the "show" modifier, scrolling the viewport so the provided
+// edge of the target element is visible after the swap.
+func (s Swap) Show(position ScrollPosition) Swap {
+	s.show = &swapScroll{position: position}
+	return s
+}
+
+// ShowTarget sets the "show" modifier, scrolling the viewport so the provided
+// edge of the element matching the CSS selector is visible after the swap.
+// The selector "window" refers to the browser window.
+func (s Swap) ShowTarget(selector string, position ScrollPosition) Swap {
+	s.show = &swapScroll{selector: selector, position: position}
+	return s
+}
+
+// ShowNone sets the "show:none" modifier, disabling any scrolling into view
+// that htmx would otherwise perform, such as for boosted links.
+func (s Swap) ShowNone() Swap {
+	s.show = &swapScroll{none: true}
+	return s
+}
+
+// FocusScroll sets the "focus-scroll" modifier, which controls whether htmx
+// scrolls to a focused input element after the swap.
+func (s Swap) FocusScroll(scroll bool) Swap {
+	s.focusScroll = &scroll
+	return s
+}
+
+// Transition sets the "transition" modifier. When true, htmx uses the View
+// Transitions API when swapping content.
+func (s Swap) Transition(transition bool) Swap {
+	s.transition = &transition
+	return s
+}
+
+// Validate reports whether the specification can be understood by the htmx
+// client. The returned error wraps ErrInvalidSwap.
+func (s Swap) Validate() error {
+	if !s.style.valid() {
+		return fmt.Errorf("%w: unknown swap style %q", ErrInvalidSwap, s.style)
+	}
+	if err := validateSwapDelay("swap", s.swapDelay); err != nil {
+		return err
+	}
+	if err := validateSwapDelay("settle", s.settleDelay); err != nil {
+		return err
+	}
+	if err := s.scroll.validate("scroll"); err != nil {
+		return err
+	}
+	return s.show.validate("show")
+}
+
+func validateSwapDelay(modifier string, d *time.Duration) error {
+	switch {
+	case d == nil:
+		return nil
+	case *d < 0:
+		return fmt.Errorf("%w: negative %s delay %s", ErrInvalidSwap, modifier, *d)
+	case *d%time.Millisecond != 0:
+		return fmt.Errorf("%w: %s delay %s is not a whole number of milliseconds", ErrInvalidSwap, modifier, *d)
+	}
+	return nil
+}
+
+func (s *swapScroll) validate(modifier string) error {
+	switch {
+	case s == nil || s.none:
+		return nil
+	case s.position != ScrollTop && s.position != ScrollBottom:
+		return fmt.Errorf("%w: unknown %s position %q", ErrInvalidSwap, modifier, s.position)
+	case s.selector == "":
+		return nil
+	case selectorProblem(s.selector) != "":
+		return fmt.Errorf("%w: %s selector %s", ErrInvalidSwap, modifier, selectorProblem(s.selector))
+	case strings.IndexFunc(s.selector, unicode.IsSpace) >= 0:
+		// htmx splits the specification on whitespace, so selectors within
+		// modifiers cannot contain any.
+		return fmt.Errorf("%w: %s selector %q contains whitespace", ErrInvalidSwap, modifier, s.selector)
+	}
+	return nil
+}
+
+// String renders the specification in the format expected by the "hx-swap"
+// attribute and "HX-Reswap" header. Invalid specifications are rendered as
+// is; use Validate() to check them first.
+func (s Swap) String() string {
+	parts := []string{string(s.style)}
+	if s.swapDelay != nil {
+		parts = append(parts, "swap:"+formatSwapDelay(*s.swapDelay))
+	}
+	if s.settleDelay != nil {
+		parts = append(parts, "settle:"+formatSwapDelay(*s.settleDelay))
+	}
+	if s.ignoreTitle != nil {
+		parts = append(parts, "ignoreTitle:"+strconv.FormatBool(*s.ignoreTitle))
+	}
+	if s.scroll != nil {
+		parts = append(parts, "scroll:"+s.scroll.String())
+	}
+	if s.show != nil {
+		parts = append(parts, "show:"+s.show.String())
+	}
+	if s.focusScroll != nil {
+		parts = append(parts, "focus-scroll:"+strconv.FormatBool(*s.focusScroll))
+	}
+	if s.transition != nil {
+		parts = append(parts, "transition:"+strconv.FormatBool(*s.transition))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s swapScroll) String() string {
+	if s.none {
+		return "none"
+	} else if s.selector == "" {
+		return string(s.position)
+	}
+	return s.selector + ":" + string(s.position)
+}
+
+func formatSwapDelay(d time.Duration) string {
+	if d%time.Second == 0 {
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	}
+	return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+}
+
+// ParseSwap parses a specification in the format of the "hx-swap" attribute
+// and "HX-Reswap" header, such as "outerHTML swap:1s scroll:#el:top". As with
+// the htmx client, the style defaults to innerHTML when the specification only
+// lists modifiers, and delays without a unit are in milliseconds. The returned
+// error wraps ErrInvalidSwap.
+func ParseSwap(value string) (Swap, error) {
+	fields := strings.Fields(value)
+	swap := NewSwap(SwapInnerHTML)
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		swap.style = SwapStyle(fields[0])
+		fields = fields[1:]
+	}
+
+	for _, field := range fields {
+		modifier, arg, _ := strings.Cut(field, ":")
+		var err error
+		switch modifier {
+		case "swap":
+			swap.swapDelay, err = parseSwapDelay(modifier, arg)
+		case "settle":
+			swap.settleDelay, err = parseSwapDelay(modifier, arg)
+		case "ignoreTitle":
+			swap.ignoreTitle, err = parseSwapBool(modifier, arg)
+		case "focus-scroll":
+			swap.focusScroll, err = parseSwapBool(modifier, arg)
+		case "transition":
+			swap.transition, err = parseSwapBool(modifier, arg)
+		case "scroll":
+			swap.scroll = parseSwapScroll(arg)
+		case "show":
+			swap.show = parseSwapScroll(arg)
+		default:
+			err = fmt.Errorf("%w: unknown modifier %q", ErrInvalidSwap, field)
+		}
+		if err != nil {
+			return Swap{}, err
+		}
+	}
+
+	if err := swap.Validate(); err != nil {
+		return Swap{}, err
+	}
+	return swap, nil
 }
 
-func (m triggerEventsJSON) triggerHeaderValue() string {
-	data, err := json.Marshal(m.eventContext)
+func parseSwapDelay(modifier, arg string) (*time.Duration, error) {
+	if ms, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		d := time.Duration(ms) * time.Millisecond
+		return &d, nil
+	}
+	d, err := time.ParseDuration(arg)
 	if err != nil {
-		err = fmt.Errorf("failed to marshal trigger message event: %s", err)
-		panic(err)
+		return nil, fmt.Errorf("%w: invalid %s delay %q", ErrInvalidSwap, modifier, arg)
+	}
+	return &d, nil
+}
+
+func parseSwapBool(modifier, arg string) (*bool, error) {
+	b, err := strconv.ParseBool(arg)
+	if err != nil || (arg != "true" && arg != "false") {
+		return nil, fmt.Errorf("%w: invalid %s value %q", ErrInvalidSwap, modifier, arg)
+	}
+	return &b, nil
+}
+
+// parseSwapScroll parses the value of the "scroll" and "show" modifiers, in
+// which the position follows an optional selector.
+func parseSwapScroll(arg string) *swapScroll {
+	if arg == "none" {
+		return &swapScroll{none: true}
+	}
+	i := strings.LastIndex(arg, ":")
+	if i < 0 {
+		return &swapScroll{position: ScrollPosition(arg)}
 	}
-	return string(data)
+	return &swapScroll{selector: arg[:i], position: ScrollPosition(arg[i+1:])}
+}
+
+// Attr renders the specification as a complete "hx-swap" attribute for use
+// within html templates:
+//
+//	<button hx-post="/items" {{.Swap.Attr}}>Add</button>
+func (s Swap) Attr() template.HTMLAttr {
+	return template.HTMLAttr(`hx-swap="` + template.HTMLEscapeString(s.String()) + `"`)
 }
@@ -0,0 +1,272 @@
+package htmx
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// plainWriter hides the optional interfaces of the recorder.
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+// readerFromWriter records the copies made with ReadFrom.
+type readerFromWriter struct {
+	http.ResponseWriter
+	copies int
+}
+
+func (w *readerFromWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.copies++
+	return io.Copy(struct{ io.Writer }{w.ResponseWriter}, src)
+}
+
+func TestResponseWriterStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(w *ResponseWriter) error
+		status  int
+		written int64
+		body    string
+	}{
+		{
+			name:   "nothing written",
+			write:  func(w *ResponseWriter) error { return nil },
+			status: 0,
+		},
+		{
+			name: "write",
+			write: func(w *ResponseWriter) error {
+				_, err := w.Write([]byte("hello"))
+				return err
+			},
+			status:  http.StatusOK,
+			written: 5,
+			body:    "hello",
+		},
+		{
+			name: "write header",
+			write: func(w *ResponseWriter) error {
+				w.WriteHeader(http.StatusCreated)
+				w.WriteHeader(http.StatusTeapot)
+				_, err := w.Write([]byte("hi"))
+				return err
+			},
+			status:  http.StatusCreated,
+			written: 2,
+			body:    "hi",
+		},
+		{
+			name: "read from",
+			write: func(w *ResponseWriter) error {
+				_, err := w.ReadFrom(strings.NewReader("streamed"))
+				return err
+			},
+			status:  http.StatusOK,
+			written: 8,
+			body:    "streamed",
+		},
+		{
+			name: "copy",
+			write: func(w *ResponseWriter) error {
+				w.WriteHeader(http.StatusAccepted)
+				_, err := io.Copy(w, strings.NewReader("copied"))
+				return err
+			},
+			status:  http.StatusAccepted,
+			written: 6,
+			body:    "copied",
+		},
+		{
+			name: "flush",
+			write: func(w *ResponseWriter) error {
+				w.Flush()
+				_, err := w.Write([]byte("event"))
+				return err
+			},
+			status:  http.StatusOK,
+			written: 5,
+			body:    "event",
+		},
+		{
+			name: "hijack unsupported",
+			write: func(w *ResponseWriter) error {
+				if _, _, err := w.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+					return err
+				}
+				return nil
+			},
+			status: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			w := NewResponseWriter(rec)
+			w.SetTriggerHeader(TriggerEvents("written"))
+			if err := tt.write(w); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := w.Status(); got != tt.status {
+				t.Errorf("Status() = %d, want %d", got, tt.status)
+			}
+			if got := w.BytesWritten(); got != tt.written {
+				t.Errorf("BytesWritten() = %d, want %d", got, tt.written)
+			}
+			if got := rec.Body.String(); got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+			// read the header as sent, when the header was written.
+			if got := rec.Result().Header.Get(HeaderHXTrigger); tt.status != 0 && got != "written" {
+				t.Errorf("%s = %q, want written", HeaderHXTrigger, got)
+			}
+			if w.Hijacked() {
+				t.Error("Hijacked() = true, want false")
+			}
+		})
+	}
+}
+
+func TestResponseWriterInformationalHeader(t *testing.T) {
+	// the recorder takes the first header for the final one, unlike a server.
+	var early http.Header
+	statuses := make(chan int, 1)
+	srv := httptest.NewServer(HTMXFunc(func(w *ResponseWriter, r *Request) {
+		w.SetTriggerHeader(TriggerEvents("before"))
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.SetTriggerHeader(TriggerEvents("after"))
+		w.WriteHeader(http.StatusAccepted)
+		statuses <- w.Status()
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				early = http.Header(header)
+			}
+			return nil
+		},
+	}
+	res, err := http.DefaultClient.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if early == nil {
+		t.Fatal("no early hints received")
+	}
+	if got := early.Get(HeaderHXTrigger); got != "" {
+		t.Errorf("early hints %s = %q, want no header", HeaderHXTrigger, got)
+	}
+	if status := <-statuses; res.StatusCode != http.StatusAccepted || status != http.StatusAccepted {
+		t.Errorf("status = %d, Status() = %d, want %d", res.StatusCode, status, http.StatusAccepted)
+	}
+	if got := res.Header.Get(HeaderHXTrigger); got != "before,after" {
+		t.Errorf("%s = %q, want %q", HeaderHXTrigger, got, "before,after")
+	}
+}
+
+func TestResponseWriterOptionalInterfaces(t *testing.T) {
+	t.Run("read from", func(t *testing.T) {
+		underlying := &readerFromWriter{ResponseWriter: httptest.NewRecorder()}
+		w := NewResponseWriter(underlying)
+		// hide the WriteTo method of the reader, preferred by io.Copy.
+		src := struct{ io.Reader }{strings.NewReader("body")}
+		if n, err := io.Copy(w, src); n != 4 || err != nil {
+			t.Fatalf("io.Copy() = %d, %v", n, err)
+		}
+		if underlying.copies != 1 {
+			t.Errorf("underlying ReadFrom called %d times, want 1", underlying.copies)
+		}
+		if w.BytesWritten() != 4 {
+			t.Errorf("BytesWritten() = %d, want 4", w.BytesWritten())
+		}
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		if err := NewResponseWriter(rec).FlushError(); err != nil || !rec.Flushed {
+			t.Errorf("FlushError() = %v, flushed %v, want a flush", err, rec.Flushed)
+		}
+	})
+
+	t.Run("flush unsupported", func(t *testing.T) {
+		w := NewResponseWriter(plainWriter{httptest.NewRecorder()})
+		if _, ok := any(w).(http.Flusher); !ok {
+			t.Error("ResponseWriter does not implement http.Flusher")
+		}
+		if err := w.FlushError(); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("FlushError() = %v, want http.ErrNotSupported", err)
+		}
+		if err := http.NewResponseController(w).Flush(); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("ResponseController.Flush() = %v, want http.ErrNotSupported", err)
+		}
+		w.Flush()
+		if w.Status() != http.StatusOK {
+			t.Errorf("Status() = %d, want %d", w.Status(), http.StatusOK)
+		}
+	})
+
+	t.Run("hijack unsupported", func(t *testing.T) {
+		w := NewResponseWriter(plainWriter{httptest.NewRecorder()})
+		if _, ok := any(w).(http.Hijacker); !ok {
+			t.Error("ResponseWriter does not implement http.Hijacker")
+		}
+		if _, _, err := http.NewResponseController(w).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("ResponseController.Hijack() = %v, want http.ErrNotSupported", err)
+		}
+		if w.Hijacked() || w.Status() != 0 {
+			t.Errorf("Hijacked() = %v, Status() = %d after a failed hijack", w.Hijacked(), w.Status())
+		}
+	})
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	type result struct {
+		status   int
+		written  int64
+		hijacked bool
+	}
+	results := make(chan result, 1)
+	srv := httptest.NewServer(HTMXFunc(func(w *ResponseWriter, r *Request) {
+		conn, brw, err := w.Hijack()
+		if err != nil {
+			t.Error(err)
+			close(results)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok")
+		brw.Flush()
+		results <- result{w.Status(), w.BytesWritten(), w.Hijacked()}
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("body = %q, want ok", body)
+	}
+
+	got := <-results
+	if got.status != http.StatusSwitchingProtocols || got.written != 0 || !got.hijacked {
+		t.Errorf("Status() = %d, BytesWritten() = %d, Hijacked() = %v, want %d, 0, true",
+			got.status, got.written, got.hijacked, http.StatusSwitchingProtocols)
+	}
+}
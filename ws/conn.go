@@ -0,0 +1,368 @@
+// Package ws implements WebSocket endpoints (RFC 6455) for the htmx ws
+// extension, without third party dependencies.
+//   - https://htmx.org/extensions/ws/
+//   - https://datatracker.ietf.org/doc/html/rfc6455
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nisimpson/htmx"
+)
+
+// Close status codes defined by RFC 6455, section 7.4.1.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = opText
+	BinaryMessage MessageType = opBinary
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+const (
+	// DefaultMaxMessageSize is the largest message accepted from clients by
+	// connections without a configured limit.
+	DefaultMaxMessageSize = 1 << 20
+
+	// writeTimeout bounds the time spent writing a single frame.
+	writeTimeout = 10 * time.Second
+
+	// closeTimeout bounds the time waiting for the client to acknowledge a
+	// close frame before the connection is dropped.
+	closeTimeout = 5 * time.Second
+)
+
+// ErrClosed is returned when writing to a connection after the close frame
+// has been sent.
+var ErrClosed = errors.New("ws: connection closed")
+
+// CloseError is returned by ReadMessage when the connection is closed with a
+// close frame, sent by either party.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("ws: connection closed with status %d", e.Code)
+	}
+	return fmt.Sprintf("ws: connection closed with status %d: %s", e.Code, e.Reason)
+}
+
+// Conn is a server side WebSocket connection. Writes are safe for concurrent
+// use; ReadMessage must only be called by a single goroutine.
+type Conn struct {
+	conn           net.Conn
+	br             *bufio.Reader
+	request        *http.Request
+	maxMessageSize int64
+	readTimeout    time.Duration
+
+	writeMu   sync.Mutex
+	closeSent bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, r *http.Request, maxMessageSize int64) *Conn {
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultMaxMessageSize
+	}
+	return &Conn{conn: conn, br: br, request: r, maxMessageSize: maxMessageSize}
+}
+
+// Request returns the http request that was upgraded to the connection.
+func (c *Conn) Request() *http.Request {
+	return c.request
+}
+
+// RemoteAddr returns the network address of the client.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+type frame struct {
+	fin     bool
+	op      byte
+	payload []byte
+}
+
+// protocolError fails the connection with the close status code.
+type protocolError struct {
+	code   int
+	reason string
+}
+
+func (e *protocolError) Error() string {
+	return "ws: " + e.reason
+}
+
+func (c *Conn) readFrame() (frame, error) {
+	if c.readTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return frame{}, err
+	}
+
+	f := frame{fin: header[0]&0x80 != 0, op: header[0] & 0x0f}
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+
+	switch {
+	case header[0]&0x70 != 0:
+		return f, &protocolError{CloseProtocolError, "reserved bits set without a negotiated extension"}
+	case !masked:
+		return f, &protocolError{CloseProtocolError, "client frames must be masked"}
+	case f.op&0x8 != 0 && (!f.fin || length > 125):
+		return f, &protocolError{CloseProtocolError, "invalid control frame"}
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > uint64(c.maxMessageSize) {
+		return f, &protocolError{CloseMessageTooBig, "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return f, err
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return f, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// ReadMessage reads the next data message from the client. Ping frames are
+// answered automatically and fragmented messages are reassembled. When the
+// client closes the connection, the close is acknowledged and a *CloseError
+// is returned. Protocol violations close the connection with the relevant
+// status code.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		messageType MessageType
+		message     []byte
+		started     bool
+	)
+
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch f.op {
+		case opPing:
+			if err := c.writeFrame(opPong, f.payload); err != nil && !errors.Is(err, ErrClosed) {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.handleClose(f.payload)
+		case opText, opBinary:
+			if started {
+				return 0, nil, c.fail(&protocolError{CloseProtocolError, "expected continuation frame"})
+			}
+			started = true
+			messageType = MessageType(f.op)
+			message = f.payload
+		case opContinuation:
+			if !started {
+				return 0, nil, c.fail(&protocolError{CloseProtocolError, "unexpected continuation frame"})
+			}
+			if int64(len(message)+len(f.payload)) > c.maxMessageSize {
+				return 0, nil, c.fail(&protocolError{CloseMessageTooBig, "message too big"})
+			}
+			message = append(message, f.payload...)
+		default:
+			return 0, nil, c.fail(&protocolError{CloseProtocolError, fmt.Sprintf("unknown opcode %#x", f.op)})
+		}
+
+		if !f.fin {
+			continue
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(&protocolError{CloseInvalidPayload, "invalid utf-8 in text message"})
+		}
+		return messageType, message, nil
+	}
+}
+
+// fail closes the connection in response to the read error.
+func (c *Conn) fail(err error) error {
+	var perr *protocolError
+	if errors.As(err, &perr) {
+		c.writeClose(perr.code, perr.reason)
+	}
+	c.conn.Close()
+	return err
+}
+
+// handleClose acknowledges the close frame sent by the client and closes the
+// underlying connection.
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		closeErr = &CloseError{Code: CloseProtocolError, Reason: "invalid close frame"}
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !validCloseCode(closeErr.Code) || !utf8.ValidString(closeErr.Reason) {
+			closeErr = &CloseError{Code: CloseProtocolError, Reason: "invalid close frame"}
+		}
+	}
+
+	code := closeErr.Code
+	if code == CloseNoStatus {
+		code = CloseNormal
+	}
+	c.writeClose(code, "")
+	c.conn.Close()
+	return closeErr
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1011:
+		return code != 1004 && code != CloseNoStatus && code != 1006
+	}
+	return false
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteByte(0x80 | op)
+	switch length := len(payload); {
+	case length <= 125:
+		buf.WriteByte(byte(length))
+	case length <= 0xffff:
+		buf.WriteByte(126)
+		binary.Write(&buf, binary.BigEndian, uint16(length))
+	default:
+		buf.WriteByte(127)
+		binary.Write(&buf, binary.BigEndian, uint64(length))
+	}
+	buf.Write(payload)
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := buf.WriteTo(c.conn)
+	return err
+}
+
+func (c *Conn) writeClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	return c.writeFrame(opClose, append(payload, reason...))
+}
+
+// WriteMessage sends a data message to the client.
+func (c *Conn) WriteMessage(messageType MessageType, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("ws: invalid message type %d", messageType)
+	}
+	return c.writeFrame(byte(messageType), data)
+}
+
+// Send renders the component and sends it to the client as a text message.
+// The htmx ws extension swaps the elements of the message into the page by
+// id, as if they had the "hx-swap-oob" attribute; see htmx.OOB() for swapping
+// content into other targets.
+func (c *Conn) Send(component htmx.Component) error {
+	buf := bytes.Buffer{}
+	if err := component.RenderHTMX(&buf); err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, buf.Bytes())
+}
+
+// Ping sends a ping frame, which the client answers with a pong frame.
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// Close starts the closing handshake with a normal closure status code.
+func (c *Conn) Close() error {
+	return c.CloseWithReason(CloseNormal, "")
+}
+
+// CloseWithReason starts the closing handshake with the status code and
+// reason. The underlying connection is closed once the client acknowledges
+// the close frame, or after a timeout.
+func (c *Conn) CloseWithReason(code int, reason string) error {
+	err := c.writeClose(code, reason)
+	c.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+	time.AfterFunc(closeTimeout, func() { c.conn.Close() })
+	if errors.Is(err, ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,282 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nisimpson/htmx"
+)
+
+// DefaultPingInterval is the interval between pings sent by servers without a
+// configured interval.
+const DefaultPingInterval = 30 * time.Second
+
+// Message is a message sent by the htmx ws extension. The extension sends the
+// values of the form containing the triggering element as a JSON object, along
+// with the htmx request headers under the "HEADERS" key.
+type Message struct {
+	// The htmx request headers of the message.
+	Headers http.Header
+	// The form values of the message.
+	Values url.Values
+	// The raw message data.
+	Data []byte
+}
+
+// ParseMessage decodes the message data sent by the htmx ws extension. Values
+// that are not strings are converted to their JSON representation.
+func ParseMessage(data []byte) (*Message, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("ws: invalid message: %w", err)
+	}
+
+	m := &Message{Headers: http.Header{}, Values: url.Values{}, Data: data}
+	for key, raw := range fields {
+		if key != "HEADERS" {
+			m.Values[key] = jsonStrings(raw)
+			continue
+		}
+		headers := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &headers); err != nil {
+			return nil, fmt.Errorf("ws: invalid message headers: %w", err)
+		}
+		for name, value := range headers {
+			if values := jsonStrings(value); len(values) > 0 {
+				m.Headers.Set(name, values[0])
+			}
+		}
+	}
+	return m, nil
+}
+
+// jsonStrings converts a JSON value into form values. Arrays produce a value
+// per element, and null produces no values.
+func jsonStrings(raw json.RawMessage) []string {
+	var (
+		s     string
+		array []json.RawMessage
+	)
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return nil
+	case json.Unmarshal(raw, &s) == nil:
+		return []string{s}
+	case json.Unmarshal(raw, &array) == nil:
+		values := make([]string, 0, len(array))
+		for _, element := range array {
+			values = append(values, jsonStrings(element)...)
+		}
+		return values
+	}
+	return []string{string(raw)}
+}
+
+// HTMX returns the parsed htmx request headers of the message.
+func (m *Message) HTMX() htmx.HTMXContext {
+	return htmx.NewRequest(&http.Request{Header: m.Headers}).HTMX()
+}
+
+// Handler instances respond to messages sent by the htmx ws extension.
+type Handler interface {
+	// ServeWS is invoked for each message received on the connection. Replies
+	// are sent with the Send() method of the connection.
+	ServeWS(*Conn, *Message)
+}
+
+// The HandlerFunc type is an adapter to allow the use of ordinary functions as
+// message handlers. If f is a function with the appropriate signature,
+// HandlerFunc(f) is a Handler that calls f.
+type HandlerFunc func(*Conn, *Message)
+
+// ServeWS calls f(c, m).
+func (f HandlerFunc) ServeWS(c *Conn, m *Message) {
+	f(c, m)
+}
+
+// Server is an http handler upgrading requests to WebSocket connections, and
+// dispatching the messages of each connection to its handler. For example,
+// a chat room that appends each message to a list on every page:
+//
+//	hub := ws.NewHub()
+//	mux.Handle("/chat", ws.Server{
+//		Hub: hub,
+//		Handler: ws.HandlerFunc(func(c *ws.Conn, m *ws.Message) {
+//			hub.Broadcast(htmx.OOB(chatMessage{Text: m.Values.Get("text")}, htmx.SwapBeforeEnd, "#messages"))
+//		}),
+//	})
+//
+// along with the markup:
+//
+//	<div hx-ext="ws" ws-connect="/chat">
+//	    <div id="messages"></div>
+//	    <form ws-send><input name="text"></form>
+//	</div>
+type Server struct {
+	// Handles the messages received from the client. Messages that are not
+	// JSON objects are passed along with empty headers and values.
+	Handler Handler
+	// Invoked once the connection is established, before messages are read.
+	OnConnect func(*Conn)
+	// If set, connections are registered with the hub while open.
+	Hub *Hub
+	// Reports whether the request origin is allowed. Defaults to SameOrigin.
+	CheckOrigin func(*http.Request) bool
+	// The largest message accepted from the client. Defaults to
+	// DefaultMaxMessageSize.
+	MaxMessageSize int64
+	// The interval between pings sent to the client; connections that remain
+	// silent for twice the interval are closed. Defaults to
+	// DefaultPingInterval; a negative value disables pings.
+	PingInterval time.Duration
+}
+
+// ServeHTTP upgrades the request and serves the connection until it is closed.
+func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	checkOrigin := s.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = SameOrigin
+	}
+
+	c, err := upgrade(w, r, checkOrigin, s.MaxMessageSize)
+	if err != nil {
+		return
+	}
+	defer c.conn.Close()
+
+	if s.Hub != nil {
+		s.Hub.add(c)
+		defer s.Hub.remove(c)
+	}
+
+	interval := s.PingInterval
+	if interval == 0 {
+		interval = DefaultPingInterval
+	}
+	if interval > 0 {
+		c.readTimeout = 2 * interval
+		done := make(chan struct{})
+		defer close(done)
+		go c.keepAlive(interval, done)
+	}
+
+	if s.OnConnect != nil {
+		s.OnConnect(c)
+	}
+
+	for {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		m, err := ParseMessage(data)
+		if err != nil {
+			m = &Message{Headers: http.Header{}, Values: url.Values{}, Data: data}
+		}
+		if s.Handler != nil {
+			s.Handler.ServeWS(c, m)
+		}
+	}
+}
+
+// ServeHTMX upgrades the request through the htmx response writer.
+func (s Server) ServeHTMX(w *htmx.ResponseWriter, r *htmx.Request) {
+	s.ServeHTTP(w, r.Request)
+}
+
+func (c *Conn) keepAlive(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.Ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Hub tracks open connections, allowing content to be pushed to every client.
+// A Hub is safe for concurrent use.
+type Hub struct {
+	mu    sync.Mutex
+	conns map[*Conn]struct{}
+}
+
+// NewHub creates an empty hub.
+func NewHub() *Hub {
+	return &Hub{conns: make(map[*Conn]struct{})}
+}
+
+func (h *Hub) add(c *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.conns[c] = struct{}{}
+}
+
+func (h *Hub) remove(c *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns, c)
+}
+
+// Len returns the number of open connections.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.conns)
+}
+
+// Broadcast renders the component once and sends it to every open connection.
+// Connections that fail to receive the message are closed.
+func (h *Hub) Broadcast(component htmx.Component) error {
+	return h.BroadcastFunc(component, nil)
+}
+
+// BroadcastFunc renders the component once and sends it to every open
+// connection for which include returns true; a nil function includes every
+// connection. Connections that fail to receive the message are closed.
+func (h *Hub) BroadcastFunc(component htmx.Component, include func(*Conn) bool) error {
+	buf := bytes.Buffer{}
+	if err := component.RenderHTMX(&buf); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	conns := make([]*Conn, 0, len(h.conns))
+	for c := range h.conns {
+		if include == nil || include(c) {
+			conns = append(conns, c)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		if err := c.WriteMessage(TextMessage, buf.Bytes()); err != nil {
+			c.CloseWithReason(CloseGoingAway, "")
+		}
+	}
+	return nil
+}
+
+// Close closes every open connection with the "going away" status code.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	conns := make([]*Conn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		c.CloseWithReason(CloseGoingAway, "")
+	}
+}
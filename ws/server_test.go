@@ -0,0 +1,369 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient is a minimal WebSocket client writing raw frames.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dial starts the server and performs the opening handshake with the key.
+func dial(t *testing.T, s Server, key string) (*testClient, *http.Response) {
+	t.Helper()
+	if s.PingInterval == 0 {
+		s.PingInterval = -1
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, err = io.WriteString(conn, "GET /ws HTTP/1.1\r\n"+
+		"Host: "+srv.Listener.Addr().String()+"\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: "+key+"\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	return &testClient{t: t, conn: conn, br: br}, resp
+}
+
+// writeFrame writes a frame, masked unless unmasked is set.
+func (c *testClient) writeFrame(fin bool, op byte, payload []byte, unmasked bool) {
+	c.t.Helper()
+	b := []byte{op}
+	if fin {
+		b[0] |= 0x80
+	}
+	maskBit := byte(0x80)
+	if unmasked {
+		maskBit = 0
+	}
+	switch {
+	case len(payload) <= 125:
+		b = append(b, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		b = append(b, maskBit|126)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	default:
+		b = append(b, maskBit|127)
+		b = binary.BigEndian.AppendUint64(b, uint64(len(payload)))
+	}
+	if unmasked {
+		b = append(b, payload...)
+	} else {
+		mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+		b = append(b, mask[:]...)
+		for i, p := range payload {
+			b = append(b, p^mask[i%4])
+		}
+	}
+	if _, err := c.conn.Write(b); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// readFrame reads an unmasked server frame.
+func (c *testClient) readFrame() (fin bool, op byte, payload []byte) {
+	c.t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	if header[1]&0x80 != 0 {
+		c.t.Fatal("server frame is masked")
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.br, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return header[0]&0x80 != 0, header[0] & 0x0f, payload
+}
+
+// expectClose reads a close frame with the status code.
+func (c *testClient) expectClose(code int) {
+	c.t.Helper()
+	_, op, payload := c.readFrame()
+	if op != opClose {
+		c.t.Fatalf("opcode = %#x, want close frame", op)
+	}
+	if len(payload) < 2 {
+		c.t.Fatalf("close payload = %q, want a status code", payload)
+	}
+	if got := int(binary.BigEndian.Uint16(payload)); got != code {
+		c.t.Errorf("close status = %d, want %d", got, code)
+	}
+}
+
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
+
+var echo = HandlerFunc(func(c *Conn, m *Message) {
+	c.WriteMessage(TextMessage, m.Data)
+})
+
+const testKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+func TestHandshake(t *testing.T) {
+	_, resp := dial(t, Server{}, testKey)
+
+	// the sample handshake of RFC 6455, section 1.3.
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+	if got := resp.Header.Get("Upgrade"); !strings.EqualFold(got, "websocket") {
+		t.Errorf("Upgrade = %q, want websocket", got)
+	}
+}
+
+func TestHandshakeRefused(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		status int
+	}{
+		{name: "method", method: http.MethodPost, status: http.StatusMethodNotAllowed},
+		{name: "not an upgrade", header: map[string]string{"Upgrade": ""}, status: http.StatusBadRequest},
+		{name: "version", header: map[string]string{"Sec-WebSocket-Version": "8"}, status: http.StatusUpgradeRequired},
+		{name: "key", header: map[string]string{"Sec-WebSocket-Key": "c2hvcnQ="}, status: http.StatusBadRequest},
+		{name: "cross origin", header: map[string]string{"Origin": "https://evil.com"}, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "http://app.test/ws", nil)
+			r.Header.Set("Upgrade", "websocket")
+			r.Header.Set("Connection", "Upgrade")
+			r.Header.Set("Sec-WebSocket-Key", testKey)
+			r.Header.Set("Sec-WebSocket-Version", "13")
+			for key, value := range tt.header {
+				r.Header.Set(key, value)
+			}
+
+			rec := httptest.NewRecorder()
+			Server{}.ServeHTTP(rec, r)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestMaskedTextMessage(t *testing.T) {
+	c, _ := dial(t, Server{Handler: echo}, testKey)
+
+	c.writeFrame(true, opText, []byte("hello"), false)
+	fin, op, payload := c.readFrame()
+	if !fin || op != opText || string(payload) != "hello" {
+		t.Errorf("frame = %v %#x %q, want final text frame %q", fin, op, payload, "hello")
+	}
+
+	long := strings.Repeat("x", 300)
+	c.writeFrame(true, opText, []byte(long), false)
+	if _, _, payload := c.readFrame(); string(payload) != long {
+		t.Errorf("payload has %d bytes, want %d", len(payload), len(long))
+	}
+}
+
+func TestFragmentedMessage(t *testing.T) {
+	c, _ := dial(t, Server{Handler: echo}, testKey)
+
+	c.writeFrame(false, opText, []byte("Hel"), false)
+	// control frames may be interleaved with the fragments of a message.
+	c.writeFrame(true, opPing, []byte("p"), false)
+	c.writeFrame(false, opContinuation, []byte("lo, "), false)
+	c.writeFrame(true, opContinuation, []byte("world"), false)
+
+	if _, op, payload := c.readFrame(); op != opPong || string(payload) != "p" {
+		t.Errorf("frame = %#x %q, want pong %q", op, payload, "p")
+	}
+	if _, op, payload := c.readFrame(); op != opText || string(payload) != "Hello, world" {
+		t.Errorf("frame = %#x %q, want text %q", op, payload, "Hello, world")
+	}
+}
+
+func TestUnexpectedContinuation(t *testing.T) {
+	c, _ := dial(t, Server{Handler: echo}, testKey)
+	c.writeFrame(true, opContinuation, []byte("x"), false)
+	c.expectClose(CloseProtocolError)
+}
+
+func TestPingPong(t *testing.T) {
+	c, _ := dial(t, Server{Handler: echo}, testKey)
+
+	c.writeFrame(true, opPing, []byte("are you there"), false)
+	if _, op, payload := c.readFrame(); op != opPong || string(payload) != "are you there" {
+		t.Errorf("frame = %#x %q, want pong echoing the ping", op, payload)
+	}
+
+	// unsolicited pongs are ignored.
+	c.writeFrame(true, opPong, nil, false)
+	c.writeFrame(true, opText, []byte("still open"), false)
+	if _, op, payload := c.readFrame(); op != opText || string(payload) != "still open" {
+		t.Errorf("frame = %#x %q, want text %q", op, payload, "still open")
+	}
+}
+
+func TestServerPing(t *testing.T) {
+	c, _ := dial(t, Server{PingInterval: 20 * time.Millisecond}, testKey)
+	if _, op, _ := c.readFrame(); op != opPing {
+		t.Errorf("opcode = %#x, want ping", op)
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    int
+	}{
+		{name: "normal", payload: closePayload(CloseNormal, "bye"), want: CloseNormal},
+		{name: "application code", payload: closePayload(4000, ""), want: 4000},
+		{name: "no status", payload: nil, want: CloseNormal},
+		{name: "reserved code", payload: closePayload(CloseNoStatus, ""), want: CloseProtocolError},
+		{name: "unassigned code", payload: closePayload(2000, ""), want: CloseProtocolError},
+		{name: "truncated code", payload: []byte{0x03}, want: CloseProtocolError},
+		{name: "invalid reason", payload: closePayload(CloseNormal, "\xff"), want: CloseProtocolError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := dial(t, Server{Handler: echo}, testKey)
+			c.writeFrame(true, opClose, tt.payload, false)
+			c.expectClose(tt.want)
+
+			// the server closes the connection after acknowledging the close.
+			if _, err := c.br.ReadByte(); err != io.EOF {
+				t.Errorf("read after close = %v, want EOF", err)
+			}
+		})
+	}
+}
+
+func TestReadMessageCloseError(t *testing.T) {
+	errs := make(chan error, 1)
+	c, _ := dial(t, Server{OnConnect: func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		errs <- err
+	}}, testKey)
+
+	c.writeFrame(true, opClose, closePayload(CloseGoingAway, "leaving"), false)
+	err := <-errs
+	closeErr, ok := err.(*CloseError)
+	if !ok || closeErr.Code != CloseGoingAway || closeErr.Reason != "leaving" {
+		t.Errorf("ReadMessage() error = %v, want close error %d", err, CloseGoingAway)
+	}
+}
+
+func TestUnmaskedFrame(t *testing.T) {
+	c, _ := dial(t, Server{Handler: echo}, testKey)
+	c.writeFrame(true, opText, []byte("hello"), true)
+	c.expectClose(CloseProtocolError)
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	c, _ := dial(t, Server{Handler: echo}, testKey)
+	c.writeFrame(true, opText, []byte("\xff\xfe"), false)
+	c.expectClose(CloseInvalidPayload)
+}
+
+func TestOversizeFrame(t *testing.T) {
+	c, _ := dial(t, Server{Handler: echo, MaxMessageSize: 16}, testKey)
+	c.writeFrame(true, opText, []byte(strings.Repeat("x", 17)), false)
+	c.expectClose(CloseMessageTooBig)
+}
+
+func TestOversizeFragmentedMessage(t *testing.T) {
+	c, _ := dial(t, Server{Handler: echo, MaxMessageSize: 16}, testKey)
+	c.writeFrame(false, opText, []byte(strings.Repeat("x", 10)), false)
+	c.writeFrame(true, opContinuation, []byte(strings.Repeat("x", 10)), false)
+	c.expectClose(CloseMessageTooBig)
+}
+
+func TestMessageHeaders(t *testing.T) {
+	messages := make(chan *Message, 1)
+	c, _ := dial(t, Server{Handler: HandlerFunc(func(c *Conn, m *Message) {
+		messages <- m
+	})}, testKey)
+
+	c.writeFrame(true, opText, []byte(`{
+		"text": "hi",
+		"tags": ["a", "b"],
+		"HEADERS": {
+			"HX-Request": "true",
+			"HX-Trigger": "chat-form",
+			"HX-Trigger-Name": "chat",
+			"HX-Target": "messages",
+			"HX-Current-URL": "http://app.test/room"
+		}
+	}`), false)
+
+	m := <-messages
+	ctx := m.HTMX()
+	switch {
+	case !ctx.Request:
+		t.Error("HTMX().Request = false, want true")
+	case ctx.TriggerID != "chat-form":
+		t.Errorf("HTMX().TriggerID = %q, want %q", ctx.TriggerID, "chat-form")
+	case ctx.TriggerName != "chat":
+		t.Errorf("HTMX().TriggerName = %q, want %q", ctx.TriggerName, "chat")
+	case ctx.Target != "messages":
+		t.Errorf("HTMX().Target = %q, want %q", ctx.Target, "messages")
+	case ctx.CurrentURL == nil || ctx.CurrentURL.Path != "/room":
+		t.Errorf("HTMX().CurrentURL = %v, want http://app.test/room", ctx.CurrentURL)
+	}
+	if got := m.Values.Get("text"); got != "hi" {
+		t.Errorf("Values.Get(text) = %q, want %q", got, "hi")
+	}
+	if got := m.Values["tags"]; len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("Values[tags] = %q, want [a b]", got)
+	}
+	if _, ok := m.Values["HEADERS"]; ok {
+		t.Error("Values contains HEADERS")
+	}
+}
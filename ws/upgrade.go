@@ -0,0 +1,105 @@
+package ws
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// acceptGUID is appended to the client key when computing the accept key.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrBadHandshake is returned when the request is not a valid WebSocket
+// opening handshake.
+var ErrBadHandshake = errors.New("ws: bad handshake")
+
+// Upgrade performs the opening handshake, taking over the connection of the
+// http request. Cross origin requests are refused; use a Server to configure
+// the origin check. If the handshake fails, an http error is written to the
+// response and an error is returned.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	return upgrade(w, r, SameOrigin, DefaultMaxMessageSize)
+}
+
+func upgrade(w http.ResponseWriter, r *http.Request, checkOrigin func(*http.Request) bool, maxMessageSize int64) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+
+	switch {
+	case r.Method != http.MethodGet:
+		return nil, refuse(w, http.StatusMethodNotAllowed, "method not allowed")
+	case !headerContainsToken(r.Header, "Connection", "upgrade"),
+		!headerContainsToken(r.Header, "Upgrade", "websocket"):
+		return nil, refuse(w, http.StatusBadRequest, "not a websocket upgrade request")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, refuse(w, http.StatusUpgradeRequired, "unsupported websocket version")
+	case !validKey(key):
+		return nil, refuse(w, http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	case !checkOrigin(r):
+		return nil, refuse(w, http.StatusForbidden, "origin not allowed")
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "websocket upgrade not supported", http.StatusInternalServerError)
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := netConn.Write([]byte(response)); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	netConn.SetDeadline(time.Time{})
+
+	return newConn(netConn, brw.Reader, r, maxMessageSize), nil
+}
+
+func refuse(w http.ResponseWriter, status int, reason string) error {
+	http.Error(w, http.StatusText(status), status)
+	return fmt.Errorf("%w: %s", ErrBadHandshake, reason)
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func validKey(key string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(decoded) == 16
+}
+
+// headerContainsToken returns true if the comma separated header values
+// contain the token, ignoring case.
+func headerContainsToken(h http.Header, key, token string) bool {
+	for _, value := range h.Values(key) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SameOrigin accepts requests without an "Origin" header, and requests whose
+// origin host matches the host of the request. Browsers always send the
+// header, so this prevents other sites from opening connections with the
+// credentials of the user.
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}